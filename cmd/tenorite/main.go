@@ -3,7 +3,9 @@ package main
 import (
     "os"
     "io"
+    "fmt"
     "0Walle/Tenorite/compiler"
+    "0Walle/Tenorite/token"
     
     "flag"
 )
@@ -23,11 +25,23 @@ func main() {
         return
     }
 
-    source, _ := io.ReadAll(f)
+    source, err := io.ReadAll(f)
     if err != nil {
         fmt.Printf("%s\n", err.Error())
         return
     }
 
-    compiler.Compile(string(source)+"\n", *file)
-}
\ No newline at end of file
+    diagnostics := compiler.Compile(string(source)+"\n", *file)
+    for _, diag := range diagnostics {
+        fmt.Fprintf(os.Stderr, "%s\n", diag.Error())
+        if diag.File != *file {
+            continue
+        }
+        if excerpt := diag.Excerpt(string(source)); excerpt != "" {
+            fmt.Fprintf(os.Stderr, "%s\n", excerpt)
+        }
+    }
+    if token.HasErrors(diagnostics) {
+        os.Exit(1)
+    }
+}
@@ -2,9 +2,9 @@ package compiler
 
 import (
     // "io"
-    "strconv"
+    // "strconv"
     "fmt"
-    // "strings"
+    "strings"
     "regexp"
     _ "embed"
     // "os"
@@ -13,17 +13,13 @@ import (
     "0Walle/Tenorite/token"
 )
 
-type PrintReporter struct {}
-
-func (r PrintReporter) Report(line int, where string, message string)  {
-    fmt.Printf("Line %d: at `%s´: %s\n", line, where, message)
-}
-
 type CompilerState struct {
-    VM     *interpreter.TenoriteVM
-    Frames  []StackFrame
-    Frame   *StackFrame
-    Subs    []*interpreter.CodeObj
+    VM           *interpreter.TenoriteVM
+    File         string
+    Frames       []StackFrame
+    Frame        *StackFrame
+    Subs         []*interpreter.CodeObj
+    Diagnostics  []token.Diagnostic
 }
 
 type Upvalue struct {
@@ -66,6 +62,7 @@ func (comp *CompilerState) PushFrame(recv string, params []string, subName strin
 
     sub.Arity = uint16(len(params))
     sub.Name = subName
+    sub.File = comp.File
 
     comp.Frames = append(comp.Frames, StackFrame {
         Environment: make(map[string]Local, 0),
@@ -92,6 +89,8 @@ func (comp *CompilerState) PopFrame() (*interpreter.CodeObj, []Upvalue) {
 
     sub.Lines = comp.Frame.Lines
 
+    markTailCalls(sub)
+
     upvalues := comp.Frame.Upvalues
     comp.Frames = comp.Frames[:len(comp.Frames)-1]
     if len(comp.Frames) != 0 {
@@ -112,6 +111,22 @@ func (comp *CompilerState) PushConst(c interpreter.Receiver) uint16 {
     return loc
 }
 
+func (comp *CompilerState) ReserveLocal() uint16 {
+    slot := comp.Frame.Sub.LocalSize+comp.Frame.Sub.Arity+1
+    comp.Frame.Sub.LocalSize += 1
+    return slot
+}
+
+func (comp *CompilerState) AddSpan(ip int, span token.Span) {
+    comp.Frame.Sub.Spans = append(comp.Frame.Sub.Spans, interpreter.SourceSpan {
+        Ip: ip,
+        Line: span.Start.Line,
+        Column: span.Start.Column,
+        EndLine: span.End.Line,
+        EndColumn: span.End.Column,
+    })
+}
+
 func (comp *CompilerState) AddLine(line int) {
     if line >= len(comp.Frame.Lines) {
         new := make([]int, 0, line+1)
@@ -134,54 +149,53 @@ func CompileCore(ctx *interpreter.TenoriteVM) {
 
     coreInc += "\n"
 
-    scanner := token.NewScanner(coreInc, PrintReporter{})
-    tokens, hasError := scanner.Scan()
-    if hasError {
-        panic("Scanner error")
+    scanner := token.NewScanner(coreInc, "core.tenor")
+    tokens, diagnostics := scanner.Scan()
+    if token.HasErrors(diagnostics) {
+        panic(diagnostics[0])
     }
 
-    parser := parser.NewParser(tokens)
-    unit, err := parser.ParseUnit()
-    if err != nil { panic(err) }
+    parser := parser.NewParser(tokens, "core.tenor")
+    unit, diagnostics := parser.ParseUnit()
+    if token.HasErrors(diagnostics) { panic(diagnostics[0]) }
 
-    comp := CompilerState { VM: ctx }
+    comp := CompilerState { VM: ctx, File: "core.tenor" }
 
     comp.PushFrame("", nil, "__core__")
 
-    err = comp.CompileModule(unit)
-    if err != nil { panic(err) }
+    diagnostics = comp.CompileModule(unit)
+    if token.HasErrors(diagnostics) { panic(diagnostics[0]) }
 
     // for i, _ := range comp.Subs {
     //     vm.PrintSub(ctx, comp.Subs[i])
     // }
 
     sub, _ := comp.PopFrame()
-    closure := &interpreter.Closure{ sub, nil }
+    closure := &interpreter.Closure{ CodeObj: sub }
     interpreter.RunClosure(ctx, closure, []interpreter.Receiver{interpreter.NONE})
 }
 
-func Compile(source string, unitName string) {
+func Compile(source string, unitName string) []token.Diagnostic {
 
 
     ctx := interpreter.MakeVM()
     CompileCore(&ctx)
     
 
-    scanner := token.NewScanner(source, PrintReporter{})
-    tokens, hasError := scanner.Scan()
-    if hasError {
-        panic("Scanner error")
-    }
+    scanner := token.NewScanner(source, unitName)
+    tokens, diagnostics := scanner.Scan()
 
     // for _, tk := range tokens {
     //     fmt.Printf("%d`%s´ ", tk.Kind, tk.Lexeme)
     // }
     // fmt.Printf("\n")
 
-    parser := parser.NewParser(tokens)
-    unit, err := parser.ParseUnit()
-    if err != nil {
-        panic(err)
+    parser := parser.NewParser(tokens, unitName)
+    unit, parseDiagnostics := parser.ParseUnit()
+    diagnostics = append(diagnostics, parseDiagnostics...)
+    if token.HasErrors(diagnostics) {
+        token.SortDiagnostics(diagnostics)
+        return diagnostics
     }
 
     // fmt.Printf("\n")
@@ -190,6 +204,7 @@ func Compile(source string, unitName string) {
 
     comp := CompilerState {
         VM: &ctx,
+        File: unitName,
     }
 
     main := ctx.NewModule("__main__")
@@ -205,9 +220,9 @@ func Compile(source string, unitName string) {
 
     comp.PushFrame("", nil, unitName)
 
-    err = comp.CompileModule(unit)
-    if err != nil {
-        panic(err)
+    diagnostics = append(diagnostics, comp.CompileModule(unit)...)
+    if token.HasErrors(diagnostics) {
+        return diagnostics
     }
 
     sub, _ := comp.PopFrame()
@@ -220,7 +235,7 @@ func Compile(source string, unitName string) {
 
     // ctx.StackTrace = true
 
-    closure := &interpreter.Closure{ sub, nil }
+    closure := &interpreter.Closure{ CodeObj: sub }
     result, err := interpreter.RunClosure(&ctx, closure, []interpreter.Receiver{interpreter.NONE})
     if err != nil {
         panic(err)
@@ -228,16 +243,60 @@ func Compile(source string, unitName string) {
 
     string := interpreter.CallUnsafe(&ctx, result, "string", nil)
     fmt.Printf("%v\n", string)
+    return diagnostics
 }
 
+func (comp *CompilerState) Report(err error, span token.Span) {
+    diag, ok := err.(token.Diagnostic)
+    if !ok {
+        diag = token.SpanDiagnostic(comp.File, span, err.Error())
+    }
+    comp.Diagnostics = append(comp.Diagnostics, diag)
+}
 
-func (comp *CompilerState) CompileModule(unit parser.Unit) error {
+func (comp *CompilerState) CompileModule(unit parser.Unit) []token.Diagnostic {
+    comp.HoistDeclarations(unit)
     for i, stmt := range unit.Contents {
         err := comp.CompileTopLevelStmt(stmt, i == len(unit.Contents)-1)
-        if err != nil { return err }
+        if err != nil { comp.Report(err, stmt.Span()) }
     }
     comp.Frame.Write(interpreter.OP_RETURN, interpreter.OP_END)
-    return nil
+    return comp.Diagnostics
+}
+
+// Top-level names are reserved before any statement is compiled so that
+// bodies may refer to globals and types declared further down the unit.
+// Types have no initializer, so their namespaces are created up front too.
+func (comp *CompilerState) HoistDeclarations(unit parser.Unit) {
+    // A name already bound, by core or an earlier declaration, keeps its
+    // binding until the declaration runs.
+    reserve := func(sym interpreter.Symbol) {
+        if _, ok := comp.VM.TopModule.Table[sym]; !ok {
+            comp.VM.TopModule.Reserve(sym)
+        }
+    }
+
+    for _, stmt := range unit.Contents {
+        switch stmt := stmt.(type) {
+        case parser.AssignStmt:
+            if stmt.NonLocal { continue }
+            reserve(comp.VM.Symbol(stmt.Name.Value.Lexeme))
+        case parser.DestructureStmt:
+            if stmt.NonLocal { continue }
+            for _, name := range patternNames(stmt.Pattern) {
+                reserve(comp.VM.Symbol(name.Value.Lexeme))
+            }
+        case parser.TypeStmt:
+            ns := stmt.Namespace.Value
+            nssym := comp.VM.Symbol(ns)
+
+            comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(interpreter.String(ns)))
+            comp.Frame.Write(interpreter.OP_MAKE_NS)
+            reserve(nssym)
+            comp.Frame.Write(interpreter.OP_STORE_MODULE, uint16(nssym))
+            comp.Frame.Write(interpreter.OP_POP)
+        }
+    }
 }
 
 func (comp *CompilerState) CompileTopLevelStmt(stmt parser.Stmt, isLast bool) error {
@@ -254,8 +313,6 @@ func (comp *CompilerState) CompileTopLevelStmt(stmt parser.Stmt, isLast bool) er
         err := comp.CompileExpr(stmt.Value)
         if err != nil { return err }
 
-        comp.VM.TopModule.Reserve(sym)
-
         comp.Frame.Write(interpreter.OP_STORE_MODULE, uint16(sym))
         if !isLast { comp.Frame.Write(interpreter.OP_POP) }
         return nil
@@ -270,8 +327,7 @@ func (comp *CompilerState) CompileTopLevelStmt(stmt parser.Stmt, isLast bool) er
         recv := params[0]
         comp.PushFrame(recv, params[1:], fmt.Sprintf("%s#%s", ns, symbol_))
         
-        err = comp.CompileStmtList(stmt.Body)
-        if err != nil { return err }
+        comp.CompileStmtList(stmt.Body)
         comp.Frame.Write(interpreter.OP_RETURN, interpreter.OP_END)
         
         sub, upvalues := comp.PopFrame()
@@ -293,17 +349,20 @@ func (comp *CompilerState) CompileTopLevelStmt(stmt parser.Stmt, isLast bool) er
         
         comp.Frame.Write(interpreter.OP_POP)
     case parser.TypeStmt:
-        ns := stmt.Namespace.Value
-    
-        nssym := comp.VM.Symbol(ns)
-        
-        comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(interpreter.String(ns)))
-        comp.Frame.Write(interpreter.OP_MAKE_NS)
-        comp.VM.TopModule.Reserve(nssym)
-        comp.Frame.Write(interpreter.OP_STORE_MODULE, uint16(nssym))
+        if isLast {
+            nssym := comp.VM.Symbol(stmt.Namespace.Value)
+            comp.Frame.Write(interpreter.OP_LOAD_MODULE, uint16(nssym))
+        }
     case parser.LoopStmt:
         return fmt.Errorf("Invalid statement in top level of module")
-    case parser.ReturnStmt:
+    case parser.ForStmt, parser.WhileStmt:
+        return comp.CompileStmt(stmt, isLast)
+    case parser.DestructureStmt:
+        if stmt.NonLocal {
+            return fmt.Errorf("Invalid nonlocal assignment in top level of module")
+        }
+        return comp.CompileStmt(stmt, isLast)
+    case parser.ReturnStmt, parser.NonLocalReturnStmt, parser.YieldStmt:
         return fmt.Errorf("Invalid statement in top level of module")
     case parser.ExprStmt:
         err := comp.CompileExpr(stmt.X)
@@ -316,22 +375,24 @@ func (comp *CompilerState) CompileTopLevelStmt(stmt parser.Stmt, isLast bool) er
     return nil
 }
 
-func (comp *CompilerState) CompileStmtList(list []parser.Stmt) error {
+func (comp *CompilerState) CompileStmtList(list []parser.Stmt) {
     for i, stmt := range list {
         err := comp.CompileStmt(stmt, i == len(list)-1)
-        if err != nil { return err }
+        if err != nil { comp.Report(err, stmt.Span()) }
     }
-    return nil
 }
 
 func (comp *CompilerState) CompileStmt(stmt parser.Stmt, isLast bool) error {
     switch stmt := stmt.(type) {
     case parser.AssignStmt:
         return comp.CompileAssignStmt(stmt, isLast)
+    case parser.DestructureStmt:
+        return comp.CompileDestructureStmt(stmt, isLast)
     case parser.FieldAssignStmt:
         name := stmt.Name.Value.Value
         err := comp.CompileExpr(stmt.Value)
         if err != nil { return err }
+        comp.AddSpan(len(comp.Frame.Sub.Code), stmt.Span())
         comp.Frame.Write(interpreter.OP_STORE_FIELD, uint16(comp.VM.Symbol(name)))
         if !isLast { comp.Frame.Write(interpreter.OP_POP) }
         return nil
@@ -342,7 +403,28 @@ func (comp *CompilerState) CompileStmt(stmt parser.Stmt, isLast bool) error {
         comp.Frame.Write(interpreter.OP_RECURSIVE)
         return nil
     case parser.ReturnStmt: return comp.CompileReturnStmt(stmt)
+    case parser.NonLocalReturnStmt: return comp.CompileNonLocalReturnStmt(stmt)
+    case parser.YieldStmt:
+        err := comp.CompileExpr(stmt.Value)
+        if err != nil { return err }
+        comp.AddSpan(len(comp.Frame.Sub.Code), stmt.Span())
+        comp.Frame.Write(interpreter.OP_YIELD)
+        if isLast { comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(interpreter.NONE)) }
+    case parser.ForStmt:
+        err := comp.CompileForStmt(stmt)
+        if err != nil { return err }
+        if isLast { comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(interpreter.NONE)) }
+    case parser.WhileStmt:
+        err := comp.CompileWhileStmt(stmt)
+        if err != nil { return err }
+        if isLast { comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(interpreter.NONE)) }
     case parser.ExprStmt:
+        if ifexpr, ok := stmt.X.(parser.IfExpr); ok {
+            return comp.CompileIfExpr(ifexpr, isLast)
+        }
+        if matchexpr, ok := stmt.X.(parser.MatchExpr); ok {
+            return comp.CompileMatchExpr(matchexpr, isLast)
+        }
         err := comp.CompileExpr(stmt.X)
         if err != nil { return err }
         if !isLast {
@@ -364,6 +446,18 @@ func (comp *CompilerState) CompileAssignStmt(stmt parser.AssignStmt, isLast bool
 
     if !stmt.NonLocal {
         location, ok := comp.Frame.Environment[name]
+        if !ok && comp.Frame.Last == nil {
+            err := comp.CompileExpr(stmt.Value)
+            if err != nil { return err }
+
+            sym := comp.VM.Symbol(name)
+            if _, ok := comp.VM.TopModule.Table[sym]; !ok {
+                comp.VM.TopModule.Reserve(sym)
+            }
+            comp.Frame.Write(interpreter.OP_STORE_MODULE, uint16(sym))
+            if !isLast { comp.Frame.Write(interpreter.OP_POP) }
+            return nil
+        }
         if !ok {
             location = Local {
                 Slot: comp.Frame.Sub.LocalSize+comp.Frame.Sub.Arity+1,
@@ -387,6 +481,7 @@ func (comp *CompilerState) CompileAssignStmt(stmt parser.AssignStmt, isLast bool
     upvalue := findUpvalue(comp, comp.Frame, name)
     if upvalue != -1 {
         comp.Frame.Write(interpreter.OP_STORE_UPVALUE, uint16(upvalue))
+        if isLast { comp.Frame.Write(interpreter.OP_LOAD_UPVALUE, uint16(upvalue)) }
         return nil
     }
 
@@ -396,6 +491,151 @@ func (comp *CompilerState) CompileAssignStmt(stmt parser.AssignStmt, isLast bool
         return fmt.Errorf("No such name %s in nonlocal", name)
     }
     comp.Frame.Write(interpreter.OP_STORE_MODULE, uint16(sym))
+    if !isLast { comp.Frame.Write(interpreter.OP_POP) }
+    return nil
+}
+
+// `^´ inside a block returns from the method that lexically encloses it,
+// which then has to be ready to catch the unwinding. Outside of blocks it is
+// a plain return.
+func (comp *CompilerState) CompileNonLocalReturnStmt(stmt parser.NonLocalReturnStmt) error {
+    err := comp.CompileExpr(stmt.Return)
+    if err != nil { return err }
+
+    if !comp.Frame.Sub.IsBlock {
+        comp.Frame.Write(interpreter.OP_RETURN)
+        return nil
+    }
+
+    home := comp.Frame
+    for home.Sub.IsBlock && home.Last != nil {
+        home = home.Last
+    }
+    home.Sub.CatchesReturn = true
+
+    comp.AddSpan(len(comp.Frame.Sub.Code), stmt.Span())
+    comp.Frame.Write(interpreter.OP_NONLOCAL_RETURN)
+    return nil
+}
+
+func (comp *CompilerState) CompileDestructureStmt(stmt parser.DestructureStmt, isLast bool) error {
+    comp.AddLine(stmt.Line())
+
+    err := comp.CompileExpr(stmt.Value)
+    if err != nil { return err }
+
+    if !isLast {
+        return comp.CompilePattern(stmt.Pattern, stmt.NonLocal)
+    }
+
+    value := comp.ReserveLocal()
+    comp.Frame.Write(interpreter.OP_STORE_LOCAL, value)
+    comp.Frame.Write(interpreter.OP_LOAD_LOCAL, value)
+    err = comp.CompilePattern(stmt.Pattern, stmt.NonLocal)
+    if err != nil { return err }
+    comp.Frame.Write(interpreter.OP_LOAD_LOCAL, value)
+    return nil
+}
+
+// Pops the value on top of the stack and binds it to the names in pattern,
+// unpacking lists, pairs and tables along the way.
+func (comp *CompilerState) CompilePattern(pattern parser.Pattern, nonlocal bool) error {
+    switch pattern := pattern.(type) {
+    case parser.Name:
+        return comp.CompileStoreName(pattern, nonlocal)
+    case parser.ListPattern:
+        var rest uint16
+        if pattern.Rest != nil { rest = 1 }
+
+        comp.AddSpan(len(comp.Frame.Sub.Code), pattern.Span())
+        comp.Frame.Write(interpreter.OP_UNPACK_LIST, uint16(len(pattern.Items)), rest)
+        for _, item := range pattern.Items {
+            err := comp.CompilePattern(item, nonlocal)
+            if err != nil { return err }
+        }
+        if pattern.Rest != nil {
+            return comp.CompileStoreName(*pattern.Rest, nonlocal)
+        }
+    case parser.PairPattern:
+        comp.AddSpan(len(comp.Frame.Sub.Code), pattern.Span())
+        comp.Frame.Write(interpreter.OP_UNPACK_PAIR)
+        err := comp.CompilePattern(pattern.Key, nonlocal)
+        if err != nil { return err }
+        return comp.CompilePattern(pattern.Value, nonlocal)
+    case parser.TablePattern:
+        for _, entry := range pattern.Entries {
+            err := comp.CompileExpr(entry.Key)
+            if err != nil { return err }
+            span := token.Span { Start: entry.Key.Span().Start, End: entry.Value.Span().End }
+            comp.AddSpan(len(comp.Frame.Sub.Code), span)
+            comp.Frame.Write(interpreter.OP_UNPACK_KEY)
+            err = comp.CompilePattern(entry.Value, nonlocal)
+            if err != nil { return err }
+        }
+        comp.Frame.Write(interpreter.OP_POP)
+    }
+    return nil
+}
+
+func (comp *CompilerState) CompileStoreName(name parser.Name, nonlocal bool) error {
+    lexeme := name.Value.Lexeme
+
+    if !nonlocal {
+        location, ok := comp.Frame.Environment[lexeme]
+        if !ok && comp.Frame.Last == nil {
+            sym := comp.VM.Symbol(lexeme)
+            if _, ok := comp.VM.TopModule.Table[sym]; !ok {
+                comp.VM.TopModule.Reserve(sym)
+            }
+            comp.Frame.Write(interpreter.OP_STORE_MODULE, uint16(sym))
+            comp.Frame.Write(interpreter.OP_POP)
+            return nil
+        }
+        if !ok {
+            location = Local { Slot: comp.ReserveLocal() }
+            comp.Frame.Environment[lexeme] = location
+        }
+        comp.Frame.Write(interpreter.OP_STORE_LOCAL, location.Slot)
+        return nil
+    }
+
+    upvalue := findUpvalue(comp, comp.Frame, lexeme)
+    if upvalue != -1 {
+        comp.Frame.Write(interpreter.OP_STORE_UPVALUE, uint16(upvalue))
+        return nil
+    }
+
+    sym := comp.VM.Symbol(lexeme)
+    if _, ok := comp.VM.TopModule.Table[sym]; !ok {
+        return token.NewDiagnostic(comp.File, name.Value, fmt.Sprintf("No such name %s in nonlocal", lexeme))
+    }
+    comp.Frame.Write(interpreter.OP_STORE_MODULE, uint16(sym))
+    comp.Frame.Write(interpreter.OP_POP)
+    return nil
+}
+
+func patternNames(pattern parser.Pattern) []parser.Name {
+    switch pattern := pattern.(type) {
+    case parser.Name:
+        return []parser.Name { pattern }
+    case parser.ListPattern:
+        var names []parser.Name
+        for _, item := range pattern.Items {
+            names = append(names, patternNames(item)...)
+        }
+        if pattern.Rest != nil {
+            names = append(names, *pattern.Rest)
+        }
+        return names
+    case parser.PairPattern:
+        return append(patternNames(pattern.Key), patternNames(pattern.Value)...)
+    case parser.TablePattern:
+        var names []parser.Name
+        for _, entry := range pattern.Entries {
+            names = append(names, patternNames(entry.Value)...)
+        }
+        return names
+    }
     return nil
 }
 
@@ -414,6 +654,226 @@ func (comp *CompilerState) CompileReturnStmt(stmt parser.ReturnStmt) error {
     return nil
 }
 
+func (comp *CompilerState) CompileForStmt(stmt parser.ForStmt) error {
+    err := comp.CompileExpr(stmt.Seq)
+    if err != nil { return err }
+
+    seq := comp.ReserveLocal()
+    cursor := comp.ReserveLocal()
+    comp.Frame.Write(interpreter.OP_STORE_LOCAL, seq)
+    comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(interpreter.NONE))
+    comp.Frame.Write(interpreter.OP_STORE_LOCAL, cursor)
+    comp.AddLine(stmt.For.Line)
+
+    name := stmt.Name.Value.Lexeme
+    variable, ok := comp.Frame.Environment[name]
+    if !ok {
+        variable = Local { Slot: comp.ReserveLocal() }
+        comp.Frame.Environment[name] = variable
+    }
+
+    nextLabel := comp.Frame.Write(interpreter.OP_JUMP, 0)
+    body := len(comp.Frame.Sub.Code)
+
+    comp.Frame.Write(interpreter.OP_STORE_LOCAL, variable.Slot)
+    err = comp.CompileBranch(stmt.Body, false)
+    if err != nil { return err }
+
+    if comp.Frame.Environment[name].IsCaptured {
+        comp.Frame.Write(interpreter.OP_CLOSE_UPVALUE, variable.Slot)
+    }
+
+    comp.Frame.Sub.Code[nextLabel] = uint16(len(comp.Frame.Sub.Code)-nextLabel+1)
+    comp.AddSpan(len(comp.Frame.Sub.Code), stmt.Seq.Span())
+    comp.Frame.Write(interpreter.OP_ITERATE, seq)
+    loop := comp.Frame.Write(interpreter.OP_LOOP, 0)
+    comp.Frame.Sub.Code[loop] = uint16(loop-1-body-2)
+    return nil
+}
+
+func (comp *CompilerState) CompileWhileStmt(stmt parser.WhileStmt) error {
+    condLabel := comp.Frame.Write(interpreter.OP_JUMP, 0)
+    body := len(comp.Frame.Sub.Code)
+
+    err := comp.CompileBranch(stmt.Body, false)
+    if err != nil { return err }
+
+    comp.Frame.Sub.Code[condLabel] = uint16(len(comp.Frame.Sub.Code)-condLabel+1)
+    err = comp.CompileExpr(stmt.Cond)
+    if err != nil { return err }
+    comp.AddLine(stmt.While.Line)
+
+    loop := comp.Frame.Write(interpreter.OP_LOOP, 0)
+    comp.Frame.Sub.Code[loop] = uint16(loop-1-body-2)
+    return nil
+}
+
+func (comp *CompilerState) CompileIfExpr(expr parser.IfExpr, isValue bool) error {
+    err := comp.CompileExpr(expr.Cond)
+    if err != nil { return err }
+
+    elseLabel := comp.Frame.Write(interpreter.OP_JUMP_FALSE, 0)
+    comp.AddLine(expr.If.Line)
+
+    err = comp.CompileBranch(expr.Body, isValue)
+    if err != nil { return err }
+
+    endLabel := comp.Frame.Write(interpreter.OP_JUMP, 0)
+    comp.Frame.Sub.Code[elseLabel] = uint16(endLabel-elseLabel+2)
+
+    err = comp.CompileBranch(expr.Else, isValue)
+    if err != nil { return err }
+
+    comp.Frame.Sub.Code[endLabel] = uint16(len(comp.Frame.Sub.Code)-endLabel+1)
+    return nil
+}
+
+func (comp *CompilerState) CompileMatchExpr(expr parser.MatchExpr, isValue bool) error {
+    err := comp.CompileExpr(expr.Subject)
+    if err != nil { return err }
+
+    subject := comp.ReserveLocal()
+    comp.Frame.Write(interpreter.OP_STORE_LOCAL, subject)
+    comp.AddLine(expr.Match.Line)
+
+    var endLabels []int
+    for _, arm := range expr.Arms {
+        var fails []int
+        err := comp.CompileArmPattern(arm.Pattern, subject, &fails)
+        if err != nil { return err }
+
+        if arm.Guard != nil {
+            err := comp.CompileExpr(arm.Guard)
+            if err != nil { return err }
+            fails = append(fails, comp.Frame.Write(interpreter.OP_JUMP_FALSE, 0))
+        }
+
+        err = comp.CompileBranch(arm.Body, isValue)
+        if err != nil { return err }
+
+        endLabels = append(endLabels, comp.Frame.Write(interpreter.OP_JUMP, 0))
+        for _, label := range fails {
+            comp.PatchJump(label)
+        }
+    }
+
+    comp.Frame.Write(interpreter.OP_LOAD_LOCAL, subject)
+    comp.AddSpan(len(comp.Frame.Sub.Code), expr.Span())
+    comp.Frame.Write(interpreter.OP_NO_MATCH)
+
+    for _, label := range endLabels {
+        comp.PatchJump(label)
+    }
+    return nil
+}
+
+// Tests the value in slot against pattern, binding names on success. Every
+// failed test jumps with the stack as it was, the labels go into fails.
+func (comp *CompilerState) CompileArmPattern(pattern parser.Pattern, slot uint16, fails *[]int) error {
+    switch pattern := pattern.(type) {
+    case parser.Name:
+        if pattern.Value.Lexeme == "_" { return nil }
+        comp.Frame.Write(interpreter.OP_LOAD_LOCAL, slot)
+        return comp.CompileStoreName(pattern, false)
+    case parser.LiteralPattern:
+        // Numbers match by value, so 0.0 matches 0.
+        selector := "==="
+        if lit, ok := pattern.Value.(parser.BasicLiteral); ok && lit.Kind.Kind == token.NUMBER {
+            selector = "matchesNumber:"
+        }
+        comp.Frame.Write(interpreter.OP_LOAD_LOCAL, slot)
+        err := comp.CompileExpr(pattern.Value)
+        if err != nil { return err }
+        comp.Frame.Write(interpreter.OP_SYM, uint16(comp.VM.Symbol(selector)))
+        comp.Frame.Write(interpreter.OP_CALL, 1)
+        *fails = append(*fails, comp.Frame.Write(interpreter.OP_JUMP_FALSE, 0))
+    case parser.TypePattern:
+        comp.Frame.Write(interpreter.OP_LOAD_LOCAL, slot)
+        err := comp.CompileExpr(pattern.Type)
+        if err != nil { return err }
+        comp.Frame.Write(interpreter.OP_TYPE)
+        *fails = append(*fails, comp.Frame.Write(interpreter.OP_JUMP_FALSE, 0))
+        return comp.CompileArmPattern(pattern.Name, slot, fails)
+    case parser.ListPattern:
+        var rest uint16
+        if pattern.Rest != nil { rest = 1 }
+
+        comp.Frame.Write(interpreter.OP_LOAD_LOCAL, slot)
+        comp.Frame.Write(interpreter.OP_MATCH_LIST, uint16(len(pattern.Items)), rest)
+        *fails = append(*fails, comp.Frame.Write(interpreter.OP_JUMP_FALSE, 0))
+        return comp.CompileArmItems(pattern.Items, pattern.Rest, fails)
+    case parser.PairPattern:
+        comp.Frame.Write(interpreter.OP_LOAD_LOCAL, slot)
+        comp.Frame.Write(interpreter.OP_MATCH_PAIR)
+        *fails = append(*fails, comp.Frame.Write(interpreter.OP_JUMP_FALSE, 0))
+        return comp.CompileArmItems([]parser.Pattern { pattern.Key, pattern.Value }, nil, fails)
+    case parser.RegexPattern:
+        comp.Frame.Write(interpreter.OP_LOAD_LOCAL, slot)
+        err := comp.CompileExpr(pattern.Regex)
+        if err != nil { return err }
+        comp.Frame.Write(interpreter.OP_MATCH_REGEX)
+        *fails = append(*fails, comp.Frame.Write(interpreter.OP_JUMP_FALSE, 0))
+        if pattern.Groups == nil {
+            comp.Frame.Write(interpreter.OP_POP)
+            return nil
+        }
+        groups := comp.ReserveLocal()
+        comp.Frame.Write(interpreter.OP_STORE_LOCAL, groups)
+        return comp.CompileArmPattern(*pattern.Groups, groups, fails)
+    default:
+        return fmt.Errorf("Invalid pattern in match arm")
+    }
+    return nil
+}
+
+// Stores the unpacked items on the stack into their own slots before any of
+// them is tested, so that failing jumps never leave values behind.
+func (comp *CompilerState) CompileArmItems(items []parser.Pattern, rest *parser.Name, fails *[]int) error {
+    slots := make([]uint16, len(items))
+    for i := range items {
+        slots[i] = comp.ReserveLocal()
+        comp.Frame.Write(interpreter.OP_STORE_LOCAL, slots[i])
+    }
+    if rest != nil {
+        err := comp.CompileStoreName(*rest, false)
+        if err != nil { return err }
+    }
+    for i, item := range items {
+        err := comp.CompileArmPattern(item, slots[i], fails)
+        if err != nil { return err }
+    }
+    return nil
+}
+
+func (comp *CompilerState) PatchJump(label int) {
+    comp.Frame.Sub.Code[label] = uint16(len(comp.Frame.Sub.Code)-label+1)
+}
+
+func (comp *CompilerState) CompileBranch(body parser.Chunk, isValue bool) error {
+    for i, stmt := range body {
+        if isValue && i == len(body)-1 { break }
+        err := comp.CompileStmt(stmt, false)
+        if err != nil { comp.Report(err, stmt.Span()) }
+    }
+
+    if !isValue { return nil }
+
+    if len(body) == 0 {
+        comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(interpreter.NONE))
+        return nil
+    }
+
+    switch last := body[len(body)-1].(type) {
+    case parser.ReturnStmt, parser.NonLocalReturnStmt, parser.LoopStmt:
+        err := comp.CompileStmt(last, false)
+        if err != nil { return err }
+        comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(interpreter.NONE))
+    default:
+        return comp.CompileStmt(last, true)
+    }
+    return nil
+}
+
 func (comp *CompilerState) CompileExpr(expr parser.Expr) error {
     switch expr := expr.(type) {
     case parser.CallExpr:
@@ -430,17 +890,18 @@ func (comp *CompilerState) CompileExpr(expr parser.Expr) error {
 
         ranks := make([]int, len(expr.Args)+1)
         ranks[0] = expr.RRank
-        s := expr.RRank
+        ranked := expr.RRank != 0
         for i, kv := range expr.Args {
             err := comp.CompileExpr(kv.Value)
             if err != nil { return err }
 
             ranks[i+1] = kv.Rank
-            s += kv.Rank
+            ranked = ranked || kv.Rank != 0
         }
         
         comp.Frame.Write(interpreter.OP_SYM, uint16(callsym))
-        if s != 0 {
+        comp.AddSpan(len(comp.Frame.Sub.Code), expr.Span())
+        if ranked {
             comp.Frame.Write(interpreter.OP_CALL_R, nargs)
             for i := uint16(0); i < nargs+1; i++ {
                 comp.Frame.Write(uint16(ranks[i]))
@@ -458,9 +919,11 @@ func (comp *CompilerState) CompileExpr(expr parser.Expr) error {
         if err != nil { return err }
 
         if expr.Op.Op.Kind == token.TYPE {
+            comp.AddSpan(len(comp.Frame.Sub.Code), expr.Span())
             comp.Frame.Write(interpreter.OP_TYPE)
         } else {
             comp.Frame.Write(interpreter.OP_SYM, uint16(sym))
+            comp.AddSpan(len(comp.Frame.Sub.Code), expr.Span())
             if expr.YRank == 0 && expr.XRank == 0 {
                 comp.Frame.Write(interpreter.OP_CALL, 1)
             } else {
@@ -479,6 +942,7 @@ func (comp *CompilerState) CompileExpr(expr parser.Expr) error {
         if err != nil { return err }
 
         comp.Frame.Write(interpreter.OP_SYM, uint16(sym))
+        comp.AddSpan(len(comp.Frame.Sub.Code), expr.Span())
         if expr.XRank == 0 {
             comp.Frame.Write(interpreter.OP_CALL, 0)
         } else {
@@ -491,17 +955,24 @@ func (comp *CompilerState) CompileExpr(expr parser.Expr) error {
         if err != nil { return err }
         sym := comp.VM.Symbol("at:")
         comp.Frame.Write(interpreter.OP_SYM, uint16(sym))
+        comp.AddSpan(len(comp.Frame.Sub.Code), expr.Span())
         comp.Frame.Write(interpreter.OP_CALL, 1)
     case parser.ParenExpr:
         return comp.CompileExpr(expr.X)
+    case parser.IfExpr:
+        return comp.CompileIfExpr(expr, true)
+    case parser.MatchExpr:
+        return comp.CompileMatchExpr(expr, true)
+    case parser.SpreadExpr:
+        return token.SpanDiagnostic(comp.File, expr.Span(), "Unexpected `...´ outside of a pattern")
     case parser.FunctionLiteral:
         params, err := validateParams(expr.Params)
         if err != nil { return err }
         
         comp.PushFrame("", params, fmt.Sprintf("(lambda:%d)", int(expr.Line())))
+        comp.Frame.Sub.IsBlock = true
 
-        err = comp.CompileStmtList(expr.Body)
-        if err != nil { return err }
+        comp.CompileStmtList(expr.Body)
 
         for _, local := range comp.Frame.Environment {
             if local.IsCaptured {
@@ -528,6 +999,20 @@ func (comp *CompilerState) CompileExpr(expr parser.Expr) error {
             if err != nil { return err }
         }
         comp.Frame.Write(interpreter.OP_MAKE_TABLE, uint16(len(expr.Items)))
+    case parser.RecordLiteral:
+        seen := make(map[string]bool, len(expr.Fields))
+        for _, field := range expr.Fields {
+            name := strings.TrimSuffix(field.Key.Value.Value, ":")
+            if seen[name] {
+                return token.NewDiagnostic(comp.File, field.Key.Value, fmt.Sprintf("Duplicate field %s in record", name))
+            }
+            seen[name] = true
+
+            comp.Frame.Write(interpreter.OP_SYM, uint16(comp.VM.Symbol(name)))
+            err := comp.CompileExpr(field.Value)
+            if err != nil { return err }
+        }
+        comp.Frame.Write(interpreter.OP_MAKE_RECORD, uint16(len(expr.Fields)))
     case parser.Symbol:
         sym := comp.VM.Symbol(expr.Value.Value)
         comp.Frame.Write(interpreter.OP_SYM, uint16(sym))
@@ -539,14 +1024,16 @@ func (comp *CompilerState) CompileExpr(expr parser.Expr) error {
             return nil
         }
 
+        comp.AddSpan(len(comp.Frame.Sub.Code), expr.Span())
         err := findName(comp, name)
         if err != nil {
-            return fmt.Errorf("Line %d: %s", expr.Value.Line, err.Error())
+            return token.NewDiagnostic(comp.File, expr.Value, err.Error())
         }
     case parser.Field:
         name := expr.Value.Value
         sym := comp.VM.Symbol(name)
 
+        comp.AddSpan(len(comp.Frame.Sub.Code), expr.Span())
         comp.Frame.Write(interpreter.OP_LOAD_FIELD, uint16(sym))
         return nil
     case parser.BasicLiteral:
@@ -569,6 +1056,7 @@ func (comp *CompilerState) CompileExpr(expr parser.Expr) error {
         }
         comp.Frame.Write(interpreter.OP_MAKE_LIST, uint16(len(expr.Parts)))
         comp.Frame.Write(interpreter.OP_SYM, uint16(comp.VM.Symbol("join:")))
+        comp.AddSpan(len(comp.Frame.Sub.Code), expr.Span())
         comp.Frame.Write(interpreter.OP_CALL, 1)
     }
     return nil
@@ -577,21 +1065,79 @@ func (comp *CompilerState) CompileExpr(expr parser.Expr) error {
 func (comp *CompilerState) CompileLiteral(tk token.Token, value string) error {
     switch tk.Kind {
     case token.NUMBER:
-        num, err := strconv.ParseFloat(value, 64)
-        if err != nil { return err }
+        num, ok := interpreter.ParseNumber(value)
+        if !ok {
+            return token.NewDiagnostic(comp.File, tk, "Invalid number `"+tk.Lexeme+"´")
+        }
 
-        comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(interpreter.Number(num)))
+        comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(num))
     case token.RAW_STRING, token.STRING_LITERAL:
         comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(interpreter.String(value)))
     case token.REGEX:
         re, err := regexp.Compile(value)
         if err != nil { return err }
 
-        comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(interpreter.Regex{re}))
+        comp.Frame.Write(interpreter.OP_CONST, comp.PushConst(interpreter.Regex{ Regex: re }))
     }
     return nil
 }
 
+func instructionSize(sub *interpreter.CodeObj, ip int) int {
+    switch sub.Code[ip] {
+    case interpreter.OP_NOP,
+         interpreter.OP_TYPE,
+         interpreter.OP_CALL_0R1,
+         interpreter.OP_POP,
+         interpreter.OP_PRINT,
+         interpreter.OP_RETURN,
+         interpreter.OP_MAKE_NS,
+         interpreter.OP_MAKE_OBJ,
+         interpreter.OP_UNPACK_PAIR,
+         interpreter.OP_UNPACK_KEY,
+         interpreter.OP_MATCH_PAIR,
+         interpreter.OP_MATCH_REGEX,
+         interpreter.OP_NO_MATCH,
+         interpreter.OP_NONLOCAL_RETURN,
+         interpreter.OP_RECURSIVE,
+         interpreter.OP_YIELD,
+         interpreter.OP_END:
+        return 1
+    case interpreter.OP_CLOSURE:
+        codeObj := sub.Consts[sub.Code[ip+1]].(*interpreter.CodeObj)
+        return 2+2*int(codeObj.UpvalueCount)
+    case interpreter.OP_CALL_R:
+        return 2+int(sub.Code[ip+1])+1
+    case interpreter.OP_UNPACK_LIST, interpreter.OP_MATCH_LIST:
+        return 3
+    }
+    return 2
+}
+
+func isTailPosition(sub *interpreter.CodeObj, ip int) bool {
+    for ip < len(sub.Code) {
+        switch sub.Code[ip] {
+        case interpreter.OP_RETURN:
+            return true
+        case interpreter.OP_CLOSE_UPVALUE:
+            ip += 2
+        case interpreter.OP_JUMP:
+            ip += int(sub.Code[ip+1])
+        default:
+            return false
+        }
+    }
+    return false
+}
+
+func markTailCalls(sub *interpreter.CodeObj) {
+    for ip := 0; ip < len(sub.Code) && sub.Code[ip] != interpreter.OP_END; ip += instructionSize(sub, ip) {
+        if sub.Code[ip] != interpreter.OP_CALL { continue }
+        if isTailPosition(sub, ip+2) {
+            sub.Code[ip] = interpreter.OP_TAIL_CALL
+        }
+    }
+}
+
 func validateParams(params []parser.Name) ([]string, error) {
     names := make([]string, len(params))
 
@@ -0,0 +1,623 @@
+package interpreter
+
+// Arrays are lists of numbers packed into Go slices: Ints when every element
+// is an exact machine integer, Floats when every element is a Float. They
+// answer to everything a List does, and unpack into a List whenever they reach
+// a primitive that only knows Lists.
+//
+// An Array with a Shape of more than one axis is N-dimensional, its atoms are
+// stored in row-major order and its items are the major cells, the sub-arrays
+// along the first axis. Items holds the atoms of arrays that are not numeric.
+type Array struct {
+    Ints    []int64
+    Floats  []float64
+    Items   []Receiver
+    Shape   []int
+}
+
+var ArrayNs = NewNamespace("Array")
+
+func (_ Array) GetMethod(sym Symbol) (meth Receiver) {
+    meth = ArrayNs.Get(sym); if meth != nil { return }
+    meth = ListNs.Get(sym); if meth != nil { return }
+    return ObjectNs.Get(sym)
+}
+func (_ Array) Type(r Receiver) bool { return r == ListNs || r == ObjectNs }
+
+func (a Array) isFloat() bool { return a.Floats != nil }
+func (a Array) isBoxed() bool { return a.Items != nil }
+
+// Answers the number of atoms.
+func (a Array) count() int {
+    if a.isFloat() { return len(a.Floats) }
+    if a.isBoxed() { return len(a.Items) }
+    return len(a.Ints)
+}
+
+func (a Array) shape() []int {
+    if a.Shape != nil { return a.Shape }
+    return []int{ a.count() }
+}
+
+func (a Array) atom(i int) Receiver {
+    if a.isFloat() { return Float(a.Floats[i]) }
+    if a.isBoxed() { return a.Items[i] }
+    return Number(a.Ints[i])
+}
+
+func (a Array) atoms() []Receiver {
+    atoms := make([]Receiver, a.count())
+    for i := range atoms {
+        atoms[i] = a.atom(i)
+    }
+    return atoms
+}
+
+// Answers the length of the first axis.
+func (a Array) Len() int {
+    if len(a.Shape) > 1 { return a.Shape[0] }
+    return a.count()
+}
+
+// Answers the i-th major cell, an atom when the array is a vector.
+func (a Array) At(i int) Receiver {
+    if len(a.Shape) <= 1 { return a.atom(i) }
+    n := product(a.Shape[1:])
+    cell := Array { Shape: a.Shape[1:] }
+    if len(cell.Shape) == 1 { cell.Shape = nil }
+    switch {
+    case a.isFloat(): cell.Floats = a.Floats[i*n:(i+1)*n]
+    case a.isBoxed(): cell.Items = a.Items[i*n:(i+1)*n]
+    default: cell.Ints = a.Ints[i*n:(i+1)*n]
+    }
+    return cell
+}
+
+func product(shape []int) int {
+    n := 1
+    for _, axis := range shape {
+        n *= axis
+    }
+    return n
+}
+
+// Builds an array of the given shape from its atoms in row-major order,
+// packing them when they are all numbers of one kind. A vector that cannot
+// be packed is answered as a List and the empty shape answers the one atom.
+func makeArray(atoms []Receiver, shape []int) Receiver {
+    if len(shape) == 0 {
+        return atoms[0]
+    }
+    if len(shape) == 1 {
+        return packList(atoms)
+    }
+    var array Array
+    switch packed := packList(atoms).(type) {
+    case Array: array = packed
+    default: array = Array { Items: atoms }
+    }
+    if array.Ints == nil && array.Floats == nil && array.Items == nil {
+        array.Items = []Receiver{}
+    }
+    array.Shape = shape
+    return array
+}
+
+// Answers the shape of any value: nothing for an atom, the shape of an
+// Array, and for a List its length followed by the shape its items share.
+func shapeOf(r Receiver) []int {
+    switch r := r.(type) {
+    case Array: return r.shape()
+    case List:
+        if len(r.List) == 0 { return []int{ 0 } }
+        cell := shapeOf(r.List[0])
+        for _, item := range r.List[1:] {
+            if !sameShape(cell, shapeOf(item)) { return []int{ len(r.List) } }
+        }
+        return append([]int{ len(r.List) }, cell...)
+    }
+    if IsCollection(r) { return []int{ Size(r) } }
+    return []int{}
+}
+
+func sameShape(a, b []int) bool {
+    if len(a) != len(b) { return false }
+    for i := range a {
+        if a[i] != b[i] { return false }
+    }
+    return true
+}
+
+// Answers the atoms of r down to rank axes, in row-major order.
+func ravelOf(r Receiver, rank int) []Receiver {
+    if rank == 0 { return []Receiver{ r } }
+    if array, ok := r.(Array); ok && len(array.shape()) == rank {
+        return array.atoms()
+    }
+    var atoms []Receiver
+    for i := 0; i < Size(r); i++ {
+        atoms = append(atoms, ravelOf(GetAt(r, i), rank-1)...)
+    }
+    return atoms
+}
+
+// Answers r as an Array with its full shape.
+func toArray(r Receiver) Array {
+    shape := shapeOf(r)
+    switch array := makeArray(ravelOf(r, len(shape)), shape).(type) {
+    case Array:
+        return array
+    case List:
+        return Array { Items: array.List }
+    }
+    return Array { Items: []Receiver{ r } }
+}
+
+// Stacks the results of a rank call over an N-dimensional array back into
+// one, when every result is a cell of the same shape.
+func assemble(source Receiver, results []Receiver) Receiver {
+    array, ok := source.(Array)
+    if !ok || len(array.Shape) <= 1 {
+        return packList(results)
+    }
+    return stack(results)
+}
+
+// Stacks results of the same shape into one Array with a new leading axis,
+// anything else is answered as a List.
+func stack(results []Receiver) Receiver {
+    if len(results) == 0 { return packList(results) }
+    cell := shapeOf(results[0])
+    if len(cell) == 0 { return packList(results) }
+    var atoms []Receiver
+    for _, result := range results {
+        switch result.(type) {
+        case List, Array:
+        default: return packList(results)
+        }
+        if !sameShape(cell, shapeOf(result)) { return packList(results) }
+        atoms = append(atoms, ravelOf(result, len(cell))...)
+    }
+    return makeArray(atoms, append([]int{ len(results) }, cell...))
+}
+
+func (a Array) list() List {
+    items := make([]Receiver, a.Len())
+    for i := range items {
+        items[i] = a.At(i)
+    }
+    return List { items }
+}
+
+// Packs items into an Array when they are all machine integers or all
+// Floats, otherwise answers them as a List.
+func packList(items []Receiver) Receiver {
+    if len(items) == 0 { return List { items } }
+    switch items[0].(type) {
+    case Number:
+        ints := make([]int64, len(items))
+        for i, item := range items {
+            n, ok := item.(Number)
+            if !ok { return List { items } }
+            ints[i] = int64(n)
+        }
+        return Array { Ints: ints }
+    case Float:
+        floats := make([]float64, len(items))
+        for i, item := range items {
+            f, ok := item.(Float)
+            if !ok { return List { items } }
+            floats[i] = float64(f)
+        }
+        return Array { Floats: floats }
+    }
+    return List { items }
+}
+
+func asList(r Receiver) (List, bool) {
+    switch r := r.(type) {
+    case List: return r, true
+    case Array: return r.list(), true
+    }
+    return List{}, false
+}
+
+// Answers args with every Array replaced by the equivalent List, args itself
+// when there are none.
+func unpackArgs(args []Receiver) []Receiver {
+    unpacked := args
+    for i, arg := range args {
+        array, ok := arg.(Array)
+        if !ok { continue }
+        if &unpacked[0] == &args[0] {
+            unpacked = append([]Receiver(nil), args...)
+        }
+        unpacked[i] = array.list()
+    }
+    return unpacked
+}
+
+var (
+    SYM_ADD = makeSymbol("+")
+    SYM_SUB = makeSymbol("-")
+    SYM_MUL = makeSymbol("*")
+    SYM_DIV = makeSymbol("/")
+    SYM_MOD = makeSymbol("%")
+    SYM_MAX = makeSymbol(">>")
+    SYM_MIN = makeSymbol("<<")
+    SYM_GT = makeSymbol(">")
+    SYM_LT = makeSymbol("<")
+    SYM_GE = makeSymbol(">=")
+    SYM_LE = makeSymbol("<=")
+    SYM_NOT_EQUALS = makeSymbol("!=")
+)
+
+// Runs a NumberNs operator elementwise over packed arrays and numbers in a
+// tight loop. ok is false when the operands, or an exact result that would
+// leave the machine integers, need the generic path instead.
+func vectorCall(msg Message, args []Receiver, frames []int) (result Receiver, ok bool) {
+    if len(args) != 2 { return nil, false }
+    if _, isPrimitive := NumberNs.Get(msg.Symbol).(Primitive); !isPrimitive { return nil, false }
+
+    var ints [2][]int64
+    var floats [2][]float64
+    var scalars [2]Receiver
+    var shape []int
+    anyFloat := false
+    for i, arg := range args {
+        if frames[i] > 0 {
+            array, isArray := arg.(Array)
+            if !isArray || array.isBoxed() || frames[i] != len(array.shape()) { return nil, false }
+            if shape != nil && !sameShape(shape, array.shape()) { return nil, false }
+            shape = array.shape()
+            ints[i], floats[i] = array.Ints, array.Floats
+            anyFloat = anyFloat || array.isFloat()
+            continue
+        }
+        switch arg.(type) {
+        case Number:
+        case Float: anyFloat = true
+        default: return nil, false
+        }
+        scalars[i] = arg
+    }
+
+    count := product(shape)
+    if anyFloat {
+        x := vectorFloats(ints[0], floats[0], scalars[0], count)
+        y := vectorFloats(ints[1], floats[1], scalars[1], count)
+        result, ok = floatKernel(msg.Symbol, x, y)
+    } else {
+        x := vectorInts(ints[0], scalars[0], count)
+        y := vectorInts(ints[1], scalars[1], count)
+        result, ok = intKernel(msg.Symbol, x, y)
+    }
+    if !ok || len(shape) == 1 { return result, ok }
+    switch flat := result.(type) {
+    case Array:
+        flat.Shape = shape
+        return flat, true
+    case List:
+        return makeArray(flat.List, shape), true
+    }
+    return result, ok
+}
+
+func vectorInts(ints []int64, scalar Receiver, size int) []int64 {
+    if ints != nil { return ints }
+    n := int64(scalar.(Number))
+    ints = make([]int64, size)
+    for i := range ints {
+        ints[i] = n
+    }
+    return ints
+}
+
+func vectorFloats(ints []int64, floats []float64, scalar Receiver, size int) []float64 {
+    if floats != nil { return floats }
+    floats = make([]float64, size)
+    if ints != nil {
+        for i, n := range ints {
+            floats[i] = float64(n)
+        }
+        return floats
+    }
+    f := toFloat(scalar)
+    for i := range floats {
+        floats[i] = f
+    }
+    return floats
+}
+
+func boolList(n int, test func(i int) bool) Receiver {
+    items := make([]Receiver, n)
+    for i := range items {
+        items[i] = toBool(test(i))
+    }
+    return List { items }
+}
+
+func intKernel(sym Symbol, x, y []int64) (Receiver, bool) {
+    n := len(x)
+    switch sym {
+    case SYM_GT: return boolList(n, func(i int) bool { return x[i] > y[i] }), true
+    case SYM_LT: return boolList(n, func(i int) bool { return x[i] < y[i] }), true
+    case SYM_GE: return boolList(n, func(i int) bool { return x[i] >= y[i] }), true
+    case SYM_LE: return boolList(n, func(i int) bool { return x[i] <= y[i] }), true
+    case SYM_EQUALS: return boolList(n, func(i int) bool { return x[i] == y[i] }), true
+    case SYM_NOT_EQUALS: return boolList(n, func(i int) bool { return x[i] != y[i] }), true
+    }
+
+    out := make([]int64, n)
+    switch sym {
+    case SYM_ADD:
+        for i := range out {
+            s := x[i] + y[i]
+            if (x[i] > 0 && y[i] > 0 && s < 0) || (x[i] < 0 && y[i] < 0 && s >= 0) { return nil, false }
+            out[i] = s
+        }
+    case SYM_SUB:
+        for i := range out {
+            s := x[i] - y[i]
+            if (x[i] >= 0 && y[i] < 0 && s < 0) || (x[i] < 0 && y[i] > 0 && s >= 0) { return nil, false }
+            out[i] = s
+        }
+    case SYM_MUL:
+        for i := range out {
+            r, ok := intArith('*', x[i], y[i])
+            if !ok { return nil, false }
+            out[i] = int64(r.(Number))
+        }
+    case SYM_MOD:
+        for i := range out {
+            if y[i] == 0 { return nil, false }
+            out[i] = x[i] % y[i]
+        }
+    case SYM_MAX:
+        for i := range out {
+            out[i] = x[i]
+            if y[i] > x[i] { out[i] = y[i] }
+        }
+    case SYM_MIN:
+        for i := range out {
+            out[i] = x[i]
+            if y[i] < x[i] { out[i] = y[i] }
+        }
+    default:
+        return nil, false
+    }
+    return Array { Ints: out }, true
+}
+
+func floatKernel(sym Symbol, x, y []float64) (Receiver, bool) {
+    n := len(x)
+    switch sym {
+    case SYM_GT: return boolList(n, func(i int) bool { return x[i] > y[i] }), true
+    case SYM_LT: return boolList(n, func(i int) bool { return x[i] < y[i] }), true
+    case SYM_GE: return boolList(n, func(i int) bool { return x[i] >= y[i] }), true
+    case SYM_LE: return boolList(n, func(i int) bool { return x[i] <= y[i] }), true
+    case SYM_EQUALS: return boolList(n, func(i int) bool { return x[i] == y[i] }), true
+    case SYM_NOT_EQUALS: return boolList(n, func(i int) bool { return x[i] != y[i] }), true
+    }
+
+    out := make([]float64, n)
+    switch sym {
+    case SYM_ADD:
+        for i := range out { out[i] = x[i] + y[i] }
+    case SYM_SUB:
+        for i := range out { out[i] = x[i] - y[i] }
+    case SYM_MUL:
+        for i := range out { out[i] = x[i] * y[i] }
+    case SYM_DIV:
+        for i := range out { out[i] = x[i] / y[i] }
+    case SYM_MOD:
+        for i := range out {
+            r, _ := numArith('%', Float(x[i]), Float(y[i]))
+            out[i] = float64(r.(Float))
+        }
+    case SYM_MAX:
+        for i := range out {
+            out[i] = x[i]
+            if y[i] > x[i] { out[i] = y[i] }
+        }
+    case SYM_MIN:
+        for i := range out {
+            out[i] = x[i]
+            if y[i] < x[i] { out[i] = y[i] }
+        }
+    default:
+        return nil, false
+    }
+    return Array { Floats: out }, true
+}
+
+func ArrayLen(vm *TenoriteVM, args []Receiver) Receiver {
+    return Number(args[0].(Array).Len())
+}
+
+func ArrayAt_(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumber(vm, args[1], "Argument") { return nil }
+    array := args[0].(Array)
+    index := int(args[1].(Number))
+    if !validateIndex(vm, index, array.Len()) { return nil }
+    return array.At(index)
+}
+
+func ArrayNext(vm *TenoriteVM, args []Receiver) Receiver {
+    array := args[0].(Array)
+    if isFalsey(args[1]) {
+        if array.Len() == 0 { return NONE }
+        return Number(0)
+    }
+    if !validateNumber(vm, args[1], "Argument") { return nil }
+    index := int(args[1].(Number))
+    if index+1 >= array.Len() { return NONE }
+    return Number(index+1)
+}
+
+func ArrayList(vm *TenoriteVM, args []Receiver) Receiver {
+    return args[0].(Array).list()
+}
+
+// The primitives below take Lists as well as Arrays, reading a List of
+// equally shaped items as an array with one more axis.
+
+func validateShape(vm *TenoriteVM, value Receiver, name string) ([]int, bool) {
+    if n, ok := value.(Number); ok { value = List { []Receiver{ n } } }
+    list, ok := asList(value)
+    if !ok {
+        vm.Error = kindError("type", "%s must be a list of integers.", name)
+        return nil, false
+    }
+    shape := make([]int, len(list.List))
+    for i, axis := range list.List {
+        n, ok := axis.(Number)
+        if !ok || n < 0 {
+            vm.Error = kindError("type", "%s must be a list of non-negative integers.", name)
+            return nil, false
+        }
+        shape[i] = int(n)
+    }
+    return shape, true
+}
+
+func shapeList(shape []int) Receiver {
+    ints := make([]int64, len(shape))
+    for i, axis := range shape {
+        ints[i] = int64(axis)
+    }
+    return Array { Ints: ints }
+}
+
+func ArrayShape(vm *TenoriteVM, args []Receiver) Receiver {
+    return shapeList(shapeOf(args[0]))
+}
+
+func ArrayRavel(vm *TenoriteVM, args []Receiver) Receiver {
+    atoms := ravelOf(args[0], len(shapeOf(args[0])))
+    return makeArray(atoms, []int{ len(atoms) })
+}
+
+// Fills the new shape with the atoms of the receiver in row-major order,
+// starting over from the first atom when they run out.
+func ArrayReshape(vm *TenoriteVM, args []Receiver) Receiver {
+    shape, ok := validateShape(vm, args[1], "Shape")
+    if !ok { return nil }
+    atoms := ravelOf(args[0], len(shapeOf(args[0])))
+    n := product(shape)
+    if len(atoms) == 0 && n > 0 {
+        vm.Error = kindError("size", "Cannot reshape an empty array to %v", shape)
+        return nil
+    }
+    cycled := make([]Receiver, n)
+    for i := range cycled {
+        cycled[i] = atoms[i % len(atoms)]
+    }
+    return makeArray(cycled, shape)
+}
+
+// Moves axis i of the array to axis perm[i] of the result.
+func transpose(vm *TenoriteVM, array Array, perm []int) Receiver {
+    shape := array.shape()
+    if len(perm) != len(shape) {
+        vm.Error = kindError("size", "Permutation %v does not match rank %d", perm, len(shape))
+        return nil
+    }
+    result := make([]int, len(shape))
+    seen := make([]bool, len(shape))
+    for i, axis := range perm {
+        if axis >= len(shape) || seen[axis] {
+            vm.Error = kindError("index", "%v is not a permutation of the axes", perm)
+            return nil
+        }
+        seen[axis] = true
+        result[axis] = shape[i]
+    }
+
+    strides := make([]int, len(shape))
+    stride := 1
+    for i := len(shape)-1; i >= 0; i-- {
+        strides[i] = stride
+        stride *= shape[i]
+    }
+
+    atoms := make([]Receiver, array.count())
+    index := make([]int, len(result))
+    for i := range atoms {
+        source := 0
+        for axis := range shape {
+            source += index[perm[axis]] * strides[axis]
+        }
+        atoms[i] = array.atom(source)
+        for axis := len(index)-1; axis >= 0; axis-- {
+            index[axis]++
+            if index[axis] < result[axis] { break }
+            index[axis] = 0
+        }
+    }
+    return makeArray(atoms, result)
+}
+
+func ArrayTranspose(vm *TenoriteVM, args []Receiver) Receiver {
+    array := toArray(args[0])
+    perm := make([]int, len(array.shape()))
+    for i := range perm {
+        perm[i] = len(perm)-1-i
+    }
+    return transpose(vm, array, perm)
+}
+
+func ArrayTransposePerm(vm *TenoriteVM, args []Receiver) Receiver {
+    perm, ok := validateShape(vm, args[1], "Permutation")
+    if !ok { return nil }
+    return transpose(vm, toArray(args[0]), perm)
+}
+
+// Folds the array along one axis with a block of two arguments, answering
+// an array with that axis removed.
+func reduceAxis(vm *TenoriteVM, array Array, f Receiver, axis int) Receiver {
+    shape := array.shape()
+    if axis < 0 || axis >= len(shape) {
+        vm.Error = kindError("index", "Axis %d out of bounds for rank %d", axis, len(shape))
+        return nil
+    }
+    inner := product(shape[axis+1:])
+    outer := product(shape[:axis])
+    rest := append(append([]int{}, shape[:axis]...), shape[axis+1:]...)
+    if shape[axis] == 0 {
+        id, ok := identityOf(f)
+        if !ok {
+            vm.Error = errEmptyReduce(vm, f)
+            return nil
+        }
+        atoms := make([]Receiver, outer*inner)
+        for i := range atoms {
+            atoms[i] = id
+        }
+        if len(rest) == 0 { return id }
+        return makeArray(atoms, rest)
+    }
+    atoms := make([]Receiver, 0, outer*inner)
+    for o := 0; o < outer; o++ {
+        for i := 0; i < inner; i++ {
+            base := o*shape[axis]*inner + i
+            acc := array.atom(base)
+            for k := 1; k < shape[axis]; k++ {
+                var err error
+                acc, err = apply(vm, f, acc, array.atom(base + k*inner))
+                if err != nil {
+                    vm.Error = err
+                    return nil
+                }
+            }
+            atoms = append(atoms, acc)
+        }
+    }
+    if len(rest) == 0 { return atoms[0] }
+    return makeArray(atoms, rest)
+}
+
+func ArrayReduceAxis(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumber(vm, args[2], "Axis") { return nil }
+    return reduceAxis(vm, toArray(args[0]), args[1], int(args[2].(Number)))
+}
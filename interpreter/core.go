@@ -4,25 +4,31 @@ import (
     "fmt"
     "strings"
     "strconv"
-    "math"
+    "unicode/utf8"
 )
 
 
 func validateString(vm *TenoriteVM, value Receiver, name string) bool {
     _, ok := value.(String)
-    vm.Error = fmt.Errorf("%s must be string.", name)
+    vm.Error = kindError("type", "%s must be string.", name)
     return ok
 }
 
 func validateNumber(vm *TenoriteVM, value Receiver, name string) bool {
     _, ok := value.(Number)
-    vm.Error = fmt.Errorf("%s must be number.", name)
+    vm.Error = kindError("type", "%s must be an integer.", name)
     return ok
 }
 
 func validateList(vm *TenoriteVM, value Receiver, name string) bool {
     _, ok := value.(List)
-    vm.Error = fmt.Errorf("%s must be a list.", name)
+    vm.Error = kindError("type", "%s must be a list.", name)
+    return ok
+}
+
+func validateTable(vm *TenoriteVM, value Receiver, name string) bool {
+    _, ok := value.(*Table)
+    vm.Error = kindError("type", "%s must be a table.", name)
     return ok
 }
 
@@ -44,13 +50,16 @@ func toString(vm *TenoriteVM, r Receiver) string {
     case False: return "False"
     case String: return string(recv)
     case Symbol: return fmt.Sprintf("#%s", vm.SymbolStore[recv])
-    case Number: return fmt.Sprintf("%g", float64(recv))
+    case Number, BigInt, Rational, Float: return formatNumber(recv)
     case Pair: return fmt.Sprintf("%s => %s", toDebugString(vm, recv.First), toDebugString(vm, recv.Second))
-    case Range: return fmt.Sprintf("%g;%g", float64(recv.From), float64(recv.To))
+    case Range: return fmt.Sprintf("%d;%d", recv.From, recv.To)
+    case *Seq: return "<Seq>"
+    case *Coroutine: return "<Coroutine>"
     case *Namespace: return fmt.Sprintf("<%s>", recv.Name)
     case *Closure: return fmt.Sprintf("<Function>")
     case Primitive: return fmt.Sprintf("<Function>")
     case Regex: return fmt.Sprintf("#'%s'", recv.Regex.String())
+    case *ErrorObj: return fmt.Sprintf("<Error #%s: %s>", recv.Kind, recv.Message)
     case Object:
         if len(recv.Roles) == 0 {
             return "<Object>"
@@ -71,8 +80,11 @@ func toDebugString(vm *TenoriteVM, r Receiver) string {
 
 func sameObj(a, b Receiver) bool {
     switch a.(type) {
-    case List:
+    case List, Array:
         return false
+    case BigInt, Rational:
+        _, isFloat := b.(Float)
+        return !isFloat && numEqual(a, b)
     default:
         return a == b
     }
@@ -118,10 +130,28 @@ func ObjNotSame(vm *TenoriteVM, args []Receiver) Receiver {
     return toBool(!sameObj(args[0], args[1]))
 }
 
+// A number literal pattern matches numbers by value, like ==, and anything
+// else by ===, which unlike == never compares a collection item by item.
+func ObjMatchesNumber(vm *TenoriteVM, args []Receiver) Receiver {
+    if isNumeric(args[0]) && isNumeric(args[1]) {
+        return toBool(numEqual(args[0], args[1]))
+    }
+    result, err := Call(vm, Message { SYM_IDENTICAL, make([]int, 2) }, args)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
 func ObjPair(vm *TenoriteVM, args []Receiver) Receiver {
     return Pair { args[0], args[1] }
 }
 
+func ObjIterate(vm *TenoriteVM, args []Receiver) Receiver {
+    return args[1]
+}
+
 func FormatPadding(vm *TenoriteVM, args []Receiver) Receiver {
     if !validateString(vm, args[2], "Format") { return nil }
     format := args[2].(String)
@@ -171,43 +201,6 @@ func BoolString(vm *TenoriteVM, args []Receiver) Receiver {
 
 // ============ Number ============
 
-func NumAdd(vm *TenoriteVM, args []Receiver) Receiver {
-    if !validateNumber(vm, args[1], "Right side") { return nil }
-    return args[0].(Number) + args[1].(Number) }
-func NumSubtract(vm *TenoriteVM, args []Receiver) Receiver {
-    if !validateNumber(vm, args[1], "Right side") { return nil }
-    return args[0].(Number) - args[1].(Number) }
-func NumMultiply(vm *TenoriteVM, args []Receiver) Receiver {
-    if !validateNumber(vm, args[1], "Right side") { return nil }
-    return args[0].(Number) * args[1].(Number) }
-func NumDivide(vm *TenoriteVM, args []Receiver) Receiver {
-    if !validateNumber(vm, args[1], "Right side") { return nil }
-    return args[0].(Number) / args[1].(Number) }
-func NumModulo(vm *TenoriteVM, args []Receiver) Receiver {
-    if !validateNumber(vm, args[1], "Right side") { return nil }
-    return Number(int(args[0].(Number)) % int(args[1].(Number))) }
-func NumPower(vm *TenoriteVM, args []Receiver) Receiver {
-    if !validateNumber(vm, args[1], "Right side") { return nil }
-    return Number(math.Pow(float64(args[0].(Number)), float64(args[1].(Number)))) }
-func NumMin(vm *TenoriteVM, args []Receiver) Receiver {
-    if !validateNumber(vm, args[1], "Right side") { return nil }
-    return Number(math.Min(float64(args[0].(Number)), float64(args[1].(Number)))) }
-func NumMax(vm *TenoriteVM, args []Receiver) Receiver {
-    if !validateNumber(vm, args[1], "Right side") { return nil }
-    return Number(math.Max(float64(args[0].(Number)), float64(args[1].(Number)))) }
-func NumGt(vm *TenoriteVM, args []Receiver) Receiver {
-    if !validateNumber(vm, args[1], "Right side") { return nil }
-    return toBool(args[0].(Number) > args[1].(Number)) }
-func NumLt(vm *TenoriteVM, args []Receiver) Receiver {
-    if !validateNumber(vm, args[1], "Right side") { return nil }
-    return toBool(args[0].(Number) < args[1].(Number)) }
-func NumGe(vm *TenoriteVM, args []Receiver) Receiver {
-    if !validateNumber(vm, args[1], "Right side") { return nil }
-    return toBool(args[0].(Number) >= args[1].(Number)) }
-func NumLe(vm *TenoriteVM, args []Receiver) Receiver {
-    if !validateNumber(vm, args[1], "Right side") { return nil }
-    return toBool(args[0].(Number) <= args[1].(Number)) }
-
 func NumRange(vm *TenoriteVM, args []Receiver) Receiver {
     if !validateNumber(vm, args[1], "Right side") { return nil }
     from := args[0].(Number)
@@ -215,10 +208,6 @@ func NumRange(vm *TenoriteVM, args []Receiver) Receiver {
     return Range { from, to }
 }
 
-func NumString(vm *TenoriteVM, args []Receiver) Receiver {
-    return String(fmt.Sprintf("%g", float64(args[0].(Number))))
-}
-
 // ============ String ============
 
 func StringGt(vm *TenoriteVM, args []Receiver) Receiver {
@@ -314,7 +303,7 @@ func StringIndexOf2(vm *TenoriteVM, args []Receiver) Receiver {
     b := args[1].(String)
     start := int(args[2].(Number))
     if start >= len(a) {
-        vm.Error = fmt.Errorf("Index out of bounds")
+        vm.Error = kindError("index", "Index out of bounds")
         return nil
     }
     index := strings.Index(string(a[start:]), string(b))
@@ -369,6 +358,26 @@ func StringSliceEnd(vm *TenoriteVM, args []Receiver) Receiver {
     return String(runes[start:end])
 }
 
+func StringNext(vm *TenoriteVM, args []Receiver) Receiver {
+    str := args[0].(String)
+    if isFalsey(args[1]) {
+        if len(str) == 0 { return NONE }
+        return Number(0)
+    }
+    if !validateNumber(vm, args[1], "Argument") { return nil }
+    index := int(args[1].(Number))
+    _, size := utf8.DecodeRuneInString(string(str[index:]))
+    if index+size >= len(str) { return NONE }
+    return Number(index+size)
+}
+func StringIterate(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumber(vm, args[1], "Argument") { return nil }
+    str := args[0].(String)
+    index := int(args[1].(Number))
+    r, _ := utf8.DecodeRuneInString(string(str[index:]))
+    return String(r)
+}
+
 func StringString(vm *TenoriteVM, args []Receiver) Receiver {
     return args[0] }
 func StringFormat(vm *TenoriteVM, args []Receiver) Receiver {
@@ -449,7 +458,12 @@ func ListMakeTable(vm *TenoriteVM, args []Receiver) Receiver {
         vm.Error = fmt.Errorf("Keys and Values must conform.")
         return nil
     }
-    return Table { b.List, a.List }
+    table, err := newTable(vm, b.List, a.List)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return table
 }
 
 func ListConcat(vm *TenoriteVM, args []Receiver) Receiver {
@@ -470,13 +484,34 @@ func ListLen(vm *TenoriteVM, args []Receiver) Receiver {
     return Number(len(list.List))
 }
 
+func validateIndex(vm *TenoriteVM, index, size int) bool {
+    if index < 0 || index >= size {
+        vm.Error = kindError("index", "Index %d out of bounds for size %d", index, size)
+        return false
+    }
+    return true
+}
+
 func ListAt_(vm *TenoriteVM, args []Receiver) Receiver {
     if !validateNumber(vm, args[1], "Argument") { return nil }
     list := args[0].(List)
     index := int(args[1].(Number))
+    if !validateIndex(vm, index, len(list.List)) { return nil }
     return list.List[index]
 }
 
+func ListNext(vm *TenoriteVM, args []Receiver) Receiver {
+    list := args[0].(List)
+    if isFalsey(args[1]) {
+        if len(list.List) == 0 { return NONE }
+        return Number(0)
+    }
+    if !validateNumber(vm, args[1], "Argument") { return nil }
+    index := int(args[1].(Number))
+    if index+1 >= len(list.List) { return NONE }
+    return Number(index+1)
+}
+
 func ListCompress(vm *TenoriteVM, args []Receiver) Receiver {
     if !validateList(vm, args[1], "Argument") { return nil }
     list := args[0].(List)
@@ -545,47 +580,417 @@ func ListGroup(vm *TenoriteVM, args []Receiver) Receiver {
     if !validateList(vm, args[1], "Argument") { return nil }
     list := args[0].(List)
     groups := args[1].(List)
-    hash := make(map[Receiver]List)
-    var keys []Receiver
-    var values []Receiver
+    table := &Table{}
+    var buckets [][]Receiver
     for i, key := range groups.List {
-        bucket, ok := hash[key]
-        bucket.List = append(bucket.List, list.List[i])
-        hash[key] = bucket
-        if !ok { keys = append(keys, key) }
+        at, err := tableFind(vm, table, key)
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        if at == -1 {
+            tableSet(vm, table, key, nil)
+            buckets = append(buckets, nil)
+            at = len(buckets)-1
+        }
+        buckets[at] = append(buckets[at], list.List[i])
     }
-    values = make([]Receiver, len(keys))
-    for i, key := range keys {
-        values[i] = hash[key]
+    for i, bucket := range buckets {
+        table.Values[i] = List { bucket }
     }
-    return Table { keys, values }
+    return table
 }
 
 // ============ Table ============
 
 func TableLen(vm *TenoriteVM, args []Receiver) Receiver {
-    table := args[0].(Table)
+    table := args[0].(*Table)
     return Number(len(table.Keys))
 }
+// Keys and values answer new lists, later changes to the table leave them
+// as they were.
 func TableKeys(vm *TenoriteVM, args []Receiver) Receiver {
-    table := args[0].(Table)
-    return List { table.Keys }
+    table := args[0].(*Table)
+    return List { append([]Receiver{}, table.Keys...) }
 }
 func TableValues(vm *TenoriteVM, args []Receiver) Receiver {
-    table := args[0].(Table)
-    return List { table.Values }
+    table := args[0].(*Table)
+    return List { append([]Receiver{}, table.Values...) }
 }
 func TableAt_(vm *TenoriteVM, args []Receiver) Receiver {
-    table := args[0].(Table)
-    key := args[1]
-    for i, ikey := range table.Keys {
-        if sameObj(key, ikey) {
-            return table.Values[i]
+    value, ok, err := tableLookup(vm, args[0].(*Table), args[1])
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    if !ok { return NONE }
+    return value
+}
+
+func TableHasKey(vm *TenoriteVM, args []Receiver) Receiver {
+    i, err := tableFind(vm, args[0].(*Table), args[1])
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return toBool(i != -1)
+}
+
+// Binds a key in place and answers the table itself.
+func TableAtPut(vm *TenoriteVM, args []Receiver) Receiver {
+    err := tableSet(vm, args[0].(*Table), args[1], args[2])
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return args[0]
+}
+
+// Removes a key in place and answers its value, or None when it was absent.
+func TableRemoveKey(vm *TenoriteVM, args []Receiver) Receiver {
+    table := args[0].(*Table)
+    i, err := tableFind(vm, table, args[1])
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    if i == -1 { return NONE }
+    value := table.Values[i]
+    table.removeAt(i)
+    return value
+}
+
+func TableCopy(vm *TenoriteVM, args []Receiver) Receiver {
+    return args[0].(*Table).copy()
+}
+
+// Answers a new table with the entries of both tables, the argument's values
+// taking precedence.
+func TableMerge(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateTable(vm, args[1], "Argument") { return nil }
+    result := args[0].(*Table).copy()
+    other := args[1].(*Table)
+    for i, key := range other.Keys {
+        err := tableSet(vm, result, key, other.Values[i])
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+    }
+    return result
+}
+
+// Answers a new table with the entries of both tables, values of keys
+// present in both combined by the block as (block value: mine value: theirs).
+func TableMergeWith(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateTable(vm, args[1], "Argument") { return nil }
+    result := args[0].(*Table).copy()
+    other := args[1].(*Table)
+    for i, key := range other.Keys {
+        value := other.Values[i]
+        at, err := tableFind(vm, result, key)
+        if err == nil && at != -1 {
+            value, err = Run(vm, args[2], []Receiver{ args[2], result.Values[at], value })
+        }
+        if err == nil {
+            err = tableSet(vm, result, key, value)
+        }
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+    }
+    return result
+}
+
+// Answers a new table keeping the entries whose mask element is truthy.
+func TableCompress(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateList(vm, args[1], "Argument") { return nil }
+    table := args[0].(*Table)
+    mask := args[1].(List)
+    if len(table.Keys) != len(mask.List) {
+        vm.Error = kindError("size", "Differing sizes")
+        return nil
+    }
+    result := &Table{ []Receiver{}, []Receiver{}, nil, nil }
+    for i, key := range table.Keys {
+        if isFalsey(mask.List[i]) { continue }
+        err := tableSet(vm, result, key, table.Values[i])
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+    }
+    return result
+}
+
+// Answers a new table mapping values to keys, later keys winning.
+func TableInvert(vm *TenoriteVM, args []Receiver) Receiver {
+    table := args[0].(*Table)
+    result, err := newTable(vm, table.Values, table.Keys)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
+// Answers a new list of key => value pairs in insertion order.
+func TablePairs(vm *TenoriteVM, args []Receiver) Receiver {
+    table := args[0].(*Table)
+    pairs := make([]Receiver, len(table.Keys))
+    for i, key := range table.Keys {
+        pairs[i] = Pair { key, table.Values[i] }
+    }
+    return List { pairs }
+}
+
+// Answers a new table, a key that repeats keeps its first position and its
+// last value.
+func TableFromPairs(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateList(vm, args[1], "Argument") { return nil }
+    pairs := args[1].(List)
+    result := &Table{ []Receiver{}, []Receiver{}, nil, nil }
+    for _, item := range pairs.List {
+        pair, ok := item.(Pair)
+        if !ok {
+            vm.Error = kindError("type", "Table fromPairs: expects a list of pairs, got %s", toDebugString(vm, item))
+            return nil
+        }
+        err := tableSet(vm, result, pair.First, pair.Second)
+        if err != nil {
+            vm.Error = err
+            return nil
         }
     }
+    return result
+}
+
+func TableNext(vm *TenoriteVM, args []Receiver) Receiver {
+    table := args[0].(*Table)
+    if isFalsey(args[1]) {
+        if len(table.Keys) == 0 { return NONE }
+        return Number(0)
+    }
+    if !validateNumber(vm, args[1], "Argument") { return nil }
+    index := int(args[1].(Number))
+    if index+1 >= len(table.Keys) { return NONE }
+    return Number(index+1)
+}
+func TableIterate(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumber(vm, args[1], "Argument") { return nil }
+    table := args[0].(*Table)
+    index := int(args[1].(Number))
+    return Pair { table.Keys[index], table.Values[index] }
+}
+
+// ============ Set ============
+
+func validateSet(vm *TenoriteVM, value Receiver, name string) bool {
+    _, ok := value.(Set)
+    vm.Error = kindError("type", "%s must be a set.", name)
+    return ok
+}
+
+func newSet(vm *TenoriteVM, items []Receiver) (Set, error) {
+    members := &Table{ []Receiver{}, []Receiver{}, nil, nil }
+    for _, item := range items {
+        err := tableSet(vm, members, item, TRUE)
+        if err != nil { return Set{}, err }
+    }
+    return Set { members }, nil
+}
+
+func SetFrom(vm *TenoriteVM, args []Receiver) Receiver {
+    var items []Receiver
+    switch arg := args[1].(type) {
+    case List: items = arg.List
+    case Set: return arg
+    case *Table: items = arg.Values
+    default:
+        vm.Error = kindError("type", "Argument must be a list, set or table.")
+        return nil
+    }
+    set, err := newSet(vm, items)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return set
+}
+
+func ListAsSet(vm *TenoriteVM, args []Receiver) Receiver {
+    return SetFrom(vm, []Receiver{ SetNs, args[0] })
+}
+
+func SetList(vm *TenoriteVM, args []Receiver) Receiver {
+    members := args[0].(Set).Members
+    return List { append([]Receiver(nil), members.Keys...) }
+}
+
+func SetSize(vm *TenoriteVM, args []Receiver) Receiver {
+    return Number(len(args[0].(Set).Members.Keys))
+}
+
+func SetIncludes(vm *TenoriteVM, args []Receiver) Receiver {
+    i, err := tableFind(vm, args[0].(Set).Members, args[1])
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return toBool(i != -1)
+}
+
+func SetUnion(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateSet(vm, args[1], "Argument") { return nil }
+    members := args[0].(Set).Members.copy()
+    for _, item := range args[1].(Set).Members.Keys {
+        err := tableSet(vm, members, item, TRUE)
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+    }
+    return Set { members }
+}
+
+// Keeps the members of the receiver that are, or with keep false are not,
+// members of other.
+func setFilter(vm *TenoriteVM, self Set, other Set, keep bool) Receiver {
+    var items []Receiver
+    for _, item := range self.Members.Keys {
+        i, err := tableFind(vm, other.Members, item)
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        if (i != -1) == keep { items = append(items, item) }
+    }
+    set, err := newSet(vm, items)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return set
+}
+
+func SetIntersect(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateSet(vm, args[1], "Argument") { return nil }
+    return setFilter(vm, args[0].(Set), args[1].(Set), true)
+}
+
+func SetWithout(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateSet(vm, args[1], "Argument") { return nil }
+    return setFilter(vm, args[0].(Set), args[1].(Set), false)
+}
+
+func SetNext(vm *TenoriteVM, args []Receiver) Receiver {
+    return TableNext(vm, []Receiver{ args[0].(Set).Members, args[1] })
+}
+
+func SetIterate(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumber(vm, args[1], "Argument") { return nil }
+    return args[0].(Set).Members.Keys[int(args[1].(Number))]
+}
+
+// ============ Record ============
+
+func recordField(sym Symbol) Receiver {
+    return Primitive { func(vm *TenoriteVM, args []Receiver) Receiver {
+        return args[0].(Object).Table[sym]
+    } }
+}
+
+func RecordKeys(vm *TenoriteVM, args []Receiver) Receiver {
+    fields := args[0].(Object).Fields
+    keys := make([]Receiver, len(fields))
+    for i, field := range fields {
+        keys[i] = field
+    }
+    return List { keys }
+}
+
+func RecordValues(vm *TenoriteVM, args []Receiver) Receiver {
+    record := args[0].(Object)
+    values := make([]Receiver, len(record.Fields))
+    for i, field := range record.Fields {
+        values[i] = record.Table[field]
+    }
+    return List { values }
+}
+
+func RecordAt(vm *TenoriteVM, args []Receiver) Receiver {
+    sym, ok := args[1].(Symbol)
+    if !ok { return NONE }
+    if value, found := args[0].(Object).Table[sym]; found { return value }
     return NONE
 }
 
+func SymbolName(vm *TenoriteVM, args []Receiver) Receiver {
+    return String(vm.SymbolStore[args[0].(Symbol)])
+}
+
+// ============ Combinators ============
+
+// Symbols and Functions combine two collections: eachLeft: pairs every item
+// of the left side with the whole right side, eachRight: the other way around
+// and outer: every atom with every atom.
+func CombineEachLeft(vm *TenoriteVM, args []Receiver) Receiver {
+    result, err := combine(vm, args[0], []int{ -1, 0 }, args[1], args[2])
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
+func CombineEachRight(vm *TenoriteVM, args []Receiver) Receiver {
+    result, err := combine(vm, args[0], []int{ 0, -1 }, args[1], args[2])
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
+// The result of outer: has the shape of the left side followed by the shape
+// of the right side, Tables on either side keep their keys.
+func CombineOuter(vm *TenoriteVM, args []Receiver) Receiver {
+    x, y := args[1], args[2]
+    xShape, yShape := shapeOf(x), shapeOf(y)
+    xAtoms, yAtoms := ravelOf(x, len(xShape)), ravelOf(y, len(yShape))
+
+    atoms := make([]Receiver, 0, len(xAtoms)*len(yAtoms))
+    for _, a := range xAtoms {
+        for _, b := range yAtoms {
+            r, err := combine(vm, args[0], nil, a, b)
+            if err != nil {
+                vm.Error = err
+                return nil
+            }
+            atoms = append(atoms, r)
+        }
+    }
+
+    xTable, isXTable := x.(*Table)
+    yTable, isYTable := y.(*Table)
+    if !isXTable && !isYTable {
+        return makeArray(atoms, append(append([]int{}, xShape...), yShape...))
+    }
+
+    rows := make([]Receiver, len(xAtoms))
+    for i := range rows {
+        row := atoms[i*len(yAtoms):(i+1)*len(yAtoms)]
+        if isYTable {
+            rows[i] = yTable.withValues(row)
+        } else {
+            rows[i] = makeArray(row, yShape)
+        }
+    }
+    if isXTable { return xTable.withValues(rows) }
+    return makeArray(rows, xShape)
+}
+
 // ============ Pair ============
 
 func PairFirst(vm *TenoriteVM, args []Receiver) Receiver {
@@ -621,18 +1026,18 @@ func RangeList(vm *TenoriteVM, args []Receiver) Receiver {
     var size int
     if r.From < r.To {
         size = int(r.To - r.From)
-        slice := make([]Receiver, int(size+1))
+        slice := make([]int64, int(size+1))
         for i := 0; i < size+1; i++ {
-            slice[i] = Number(i)+r.From
+            slice[i] = int64(r.From)+int64(i)
         }
-        return List { slice }
+        return Array { Ints: slice }
     } else {
         size = int(r.From - r.To)
-        slice := make([]Receiver, int(size+1))
+        slice := make([]int64, int(size+1))
         for i := 0; i < size+1; i++ {
-            slice[i] = r.From-Number(i)
+            slice[i] = int64(r.From)-int64(i)
         }
-        return List { slice }
+        return Array { Ints: slice }
     }
     
 }
@@ -653,7 +1058,7 @@ func RangeNext(vm *TenoriteVM, args []Receiver) Receiver {
 
 func SystemAssert(vm *TenoriteVM, args []Receiver) Receiver {
     if isFalsey(args[1]) {
-        vm.Error = fmt.Errorf("Assertion Failed")
+        vm.Error = kindError("assert", "Assertion Failed")
         return nil
     }
     return NONE
@@ -665,7 +1070,7 @@ func SystemWriteString(vm *TenoriteVM, args []Receiver) Receiver {
 }
 
 func SystemPanic(vm *TenoriteVM, args []Receiver) Receiver {
-    vm.Error = fmt.Errorf("%v", args[1])
+    vm.Error = kindError("panic", "%v", args[1])
     return nil
 }
 
@@ -713,8 +1118,10 @@ func PreInitializeCore(vm *TenoriteVM) {
 
     ObjectNs.Set(vm.Symbol("==="), Primitive { ObjSame })
     ObjectNs.Set(vm.Symbol("!=="), Primitive { ObjNotSame })
+    ObjectNs.Set(vm.Symbol("matchesNumber:"), Primitive { ObjMatchesNumber })
     ObjectNs.Set(vm.Symbol("=>"), Primitive { ObjPair })
     ObjectNs.Set(vm.Symbol("string"), Primitive { ObjString })
+    ObjectNs.Set(vm.Symbol("iterate:"), Primitive { ObjIterate })
 
     NamespaceNs.Set(vm.Symbol("new:"), Primitive { NamespaceMake })
     
@@ -723,22 +1130,30 @@ func PreInitializeCore(vm *TenoriteVM) {
     BoolNs.Set(vm.Symbol("not"), Primitive { BoolNot })
     BoolNs.Set(vm.Symbol("string"), Primitive { BoolString })
 
-    NumberNs.Set(vm.Symbol("+"), Primitive { NumAdd })
-    NumberNs.Set(vm.Symbol("-"), Primitive { NumSubtract })
-    NumberNs.Set(vm.Symbol("*"), Primitive { NumMultiply })
-    NumberNs.Set(vm.Symbol("/"), Primitive { NumDivide })
-    NumberNs.Set(vm.Symbol("%"), Primitive { NumModulo })
+    NumberNs.Set(vm.Symbol("+"), numPrimitive('+'))
+    NumberNs.Set(vm.Symbol("-"), numPrimitive('-'))
+    NumberNs.Set(vm.Symbol("*"), numPrimitive('*'))
+    NumberNs.Set(vm.Symbol("/"), numPrimitive('/'))
+    NumberNs.Set(vm.Symbol("%"), numPrimitive('%'))
     NumberNs.Set(vm.Symbol("**"), Primitive { NumPower })
-    NumberNs.Set(vm.Symbol(">"), Primitive { NumGt })
-    NumberNs.Set(vm.Symbol("<"), Primitive { NumLt })
-    NumberNs.Set(vm.Symbol(">="), Primitive { NumGe })
-    NumberNs.Set(vm.Symbol("<="), Primitive { NumLe })
-    NumberNs.Set(vm.Symbol("=="), Primitive { ObjSame })
-    NumberNs.Set(vm.Symbol("!="), Primitive { ObjNotSame })
+    NumberNs.Set(vm.Symbol(">"), numComparison(func(c int) bool { return c > 0 }))
+    NumberNs.Set(vm.Symbol("<"), numComparison(func(c int) bool { return c < 0 }))
+    NumberNs.Set(vm.Symbol(">="), numComparison(func(c int) bool { return c >= 0 }))
+    NumberNs.Set(vm.Symbol("<="), numComparison(func(c int) bool { return c <= 0 }))
+    NumberNs.Set(vm.Symbol("=="), Primitive { NumEqual })
+    NumberNs.Set(vm.Symbol("!="), Primitive { NumNotEqual })
     NumberNs.Set(vm.Symbol(";"), Primitive { NumRange })
     NumberNs.Set(vm.Symbol(">>"), Primitive { NumMax })
     NumberNs.Set(vm.Symbol("<<"), Primitive { NumMin })
     NumberNs.Set(vm.Symbol("string"), Primitive { NumString })
+    NumberNs.Set(vm.Symbol("asFloat"), Primitive { NumAsFloat })
+    NumberNs.Set(vm.Symbol("floor"), Primitive { NumFloor })
+    NumberNs.Set(vm.Symbol("round"), Primitive { NumRound })
+    NumberNs.Set(vm.Symbol("sqrt"), Primitive { NumSqrt })
+    NumberNs.Set(vm.Symbol("isExact"), Primitive { NumIsExact })
+    NumberNs.Set(vm.Symbol("isInteger"), Primitive { NumIsInteger })
+    NumberNs.Set(vm.Symbol("numerator"), Primitive { NumNumerator })
+    NumberNs.Set(vm.Symbol("denominator"), Primitive { NumDenominator })
 
     StringNs.Set(vm.Symbol(">"), Primitive { StringGt })
     StringNs.Set(vm.Symbol("<"), Primitive { StringLt })
@@ -766,18 +1181,22 @@ func PreInitializeCore(vm *TenoriteVM) {
     StringNs.Set(vm.Symbol("slice:end:"), Primitive { StringSliceEnd })
     StringNs.Set(vm.Symbol("%%"), Primitive { StringFormat })
     StringNs.Set(vm.Symbol("string"), Primitive { StringString })
+    StringNs.Set(vm.Symbol("next:"), Primitive { StringNext })
+    StringNs.Set(vm.Symbol("iterate:"), Primitive { StringIterate })
 
     StringNs.Set(vm.Symbol("findRegex:"), Primitive { StringFindRegex })
     StringNs.Set(vm.Symbol("findRegex:start:"), Primitive { StringFindRegexStart })
 
     FunctionNs.Set(vm.Symbol("arity"), Primitive { FunctionArity })
-    FunctionNs.Set(vm.Symbol("call"), Primitive { FunctionCall })
-    FunctionNs.Set(vm.Symbol("value:"), Primitive { FunctionCall })
-    FunctionNs.Set(vm.Symbol("value:value:"), Primitive { FunctionCall })
-    FunctionNs.Set(vm.Symbol("value:value:value:"), Primitive { FunctionCall })
-    FunctionNs.Set(vm.Symbol("value:value:value:value:"), Primitive { FunctionCall })
-    FunctionNs.Set(vm.Symbol("value:value:value:value:value:"), Primitive { FunctionCall })
-    FunctionNs.Set(vm.Symbol("value:value:value:value:value:value:"), Primitive { FunctionCall })
+    for _, name := range []string{
+        "call", "value:", "value:value:", "value:value:value:",
+        "value:value:value:value:", "value:value:value:value:value:",
+        "value:value:value:value:value:value:",
+    } {
+        sym := vm.Symbol(name)
+        FunctionNs.Set(sym, Primitive { FunctionCall })
+        callSelectors[sym] = true
+    }
     FunctionNs.Set(vm.Symbol("callWithValues:"), Primitive { FunctionCallWithValues })
 
     ListNs.Static = NewNamespace("")
@@ -794,15 +1213,107 @@ func PreInitializeCore(vm *TenoriteVM) {
     ListNs.Set(vm.Symbol("compress:"), Primitive { ListCompress })
     ListNs.Set(vm.Symbol("slice:end:"), Primitive { ListSliceEnd })
     ListNs.Set(vm.Symbol("groupList:"), Primitive { ListGroup })
+    ListNs.Set(vm.Symbol("next:"), Primitive { ListNext })
+    ListNs.Set(vm.Symbol("iterate:"), Primitive { ListAt_ })
 
     TableNs.Set(vm.Symbol("len"), Primitive { TableLen })
     TableNs.Set(vm.Symbol("keys"), Primitive { TableKeys })
     TableNs.Set(vm.Symbol("values"), Primitive { TableValues })
     TableNs.Set(vm.Symbol("at_:"), Primitive { TableAt_ })
+    TableNs.Set(vm.Symbol("hasKey:"), Primitive { TableHasKey })
+    TableNs.Set(vm.Symbol("at:put:"), Primitive { TableAtPut })
+    TableNs.Set(vm.Symbol("removeKey:"), Primitive { TableRemoveKey })
+    TableNs.Set(vm.Symbol("copy"), Primitive { TableCopy })
+    TableNs.Set(vm.Symbol("merge:"), Primitive { TableMerge })
+    TableNs.Set(vm.Symbol("merge:with:"), Primitive { TableMergeWith })
+    TableNs.Set(vm.Symbol("compress:"), Primitive { TableCompress })
+    TableNs.Set(vm.Symbol("invert"), Primitive { TableInvert })
+    TableNs.Set(vm.Symbol("pairs"), Primitive { TablePairs })
+    TableNs.Static = NewNamespace("")
+    TableNs.Static.Set(vm.Symbol("fromPairs:"), Primitive { TableFromPairs })
+    TableNs.Set(vm.Symbol("next:"), Primitive { TableNext })
+    TableNs.Set(vm.Symbol("iterate:"), Primitive { TableIterate })
+
+    coreMod.Add(vm.Symbol("Set"), SetNs)
+    SetNs.Static = NewNamespace("")
+    SetNs.Static.Set(vm.Symbol("from:"), Primitive { SetFrom })
+    SetNs.Set(vm.Symbol("list"), Primitive { SetList })
+    SetNs.Set(vm.Symbol("size"), Primitive { SetSize })
+    SetNs.Set(vm.Symbol("len"), Primitive { SetSize })
+    SetNs.Set(vm.Symbol("includes:"), Primitive { SetIncludes })
+    SetNs.Set(vm.Symbol("union:"), Primitive { SetUnion })
+    SetNs.Set(vm.Symbol("intersect:"), Primitive { SetIntersect })
+    SetNs.Set(vm.Symbol("without:"), Primitive { SetWithout })
+    SetNs.Set(vm.Symbol("next:"), Primitive { SetNext })
+    SetNs.Set(vm.Symbol("iterate:"), Primitive { SetIterate })
+    ListNs.Set(vm.Symbol("asSet"), Primitive { ListAsSet })
+
+    ArrayNs.Set(vm.Symbol("len"), Primitive { ArrayLen })
+    ArrayNs.Set(vm.Symbol("at_:"), Primitive { ArrayAt_ })
+    ArrayNs.Set(vm.Symbol("next:"), Primitive { ArrayNext })
+    ArrayNs.Set(vm.Symbol("iterate:"), Primitive { ArrayAt_ })
+    ArrayNs.Set(vm.Symbol("list"), Primitive { ArrayList })
+    for _, ns := range []*Namespace{ ListNs, ArrayNs } {
+        ns.Set(vm.Symbol("shape"), Primitive { ArrayShape })
+        ns.Set(vm.Symbol("ravel"), Primitive { ArrayRavel })
+        ns.Set(vm.Symbol("reshape:"), Primitive { ArrayReshape })
+        ns.Set(vm.Symbol("transpose"), Primitive { ArrayTranspose })
+        ns.Set(vm.Symbol("transpose:"), Primitive { ArrayTransposePerm })
+        ns.Set(vm.Symbol("reduce:axis:"), Primitive { ArrayReduceAxis })
+    }
 
     PairNs.Set(vm.Symbol("first"), Primitive { PairFirst })
     PairNs.Set(vm.Symbol("second"), Primitive { PairSecond })
 
+    coreMod.Add(vm.Symbol("Record"), RecordNs)
+    RecordNs.Set(vm.Symbol("keys"), Primitive { RecordKeys })
+    RecordNs.Set(vm.Symbol("values"), Primitive { RecordValues })
+    RecordNs.Set(vm.Symbol("at:"), Primitive { RecordAt })
+
+    SymbolNs.Set(vm.Symbol("name"), Primitive { SymbolName })
+
+    for _, ns := range []*Namespace{ ListNs, ArrayNs, TableNs, SetNs } {
+        ns.Set(vm.Symbol("</>"), Primitive { SeqReduce })
+        ns.Set(vm.Symbol("<//>"), Primitive { SeqScan })
+        ns.Set(vm.Symbol("reduceRight:"), Primitive { SeqReduceRight })
+        ns.Set(vm.Symbol("scanRight:"), Primitive { SeqScanRight })
+        ns.Set(vm.Symbol("fold:into:"), Primitive { SeqFold })
+        ns.Set(vm.Symbol("foldRight:into:"), Primitive { SeqFoldRight })
+    }
+
+    for _, ns := range []*Namespace{ ListNs, ArrayNs, StringNs } {
+        ns.Set(vm.Symbol("unique"), Primitive { ListUnique })
+        ns.Set(vm.Symbol("nubSieve"), Primitive { ListNubSieve })
+        ns.Set(vm.Symbol("reverse"), Primitive { ListReverse })
+        ns.Set(vm.Symbol("zip:"), Primitive { ListZip })
+    }
+
+    for _, ns := range []*Namespace{ ListNs, ArrayNs } {
+        ns.Set(vm.Symbol("indexOf:"), Primitive { ListIndexOf })
+        ns.Set(vm.Symbol("contains:"), Primitive { ListContains })
+        ns.Set(vm.Symbol("memberOf:"), Primitive { ListMemberOf })
+        ns.Set(vm.Symbol("flat"), Primitive { ListFlat })
+        ns.Set(vm.Symbol("flat:"), Primitive { ListFlatDepth })
+        ns.Set(vm.Symbol(">>"), rotatePrimitive(SYM_MAX, 1))
+        ns.Set(vm.Symbol("<<"), rotatePrimitive(SYM_MIN, -1))
+    }
+
+    for _, ns := range []*Namespace{ ListNs, ArrayNs, TableNs, StringNs } {
+        ns.Set(vm.Symbol("sort"), sortPrimitive(false))
+        ns.Set(vm.Symbol("sortDescending"), sortPrimitive(true))
+        ns.Set(vm.Symbol("sortBy:"), Primitive { SeqSortBy })
+        ns.Set(vm.Symbol("sortWith:"), Primitive { SeqSortWith })
+        ns.Set(vm.Symbol("grade"), gradePrimitive(false))
+        ns.Set(vm.Symbol("gradeDown"), gradePrimitive(true))
+    }
+    TableNs.Set(vm.Symbol("sortByKey"), Primitive { TableSortByKey })
+
+    for _, ns := range []*Namespace{ SymbolNs, FunctionNs } {
+        ns.Set(vm.Symbol("eachLeft:with:"), Primitive { CombineEachLeft })
+        ns.Set(vm.Symbol("eachRight:with:"), Primitive { CombineEachRight })
+        ns.Set(vm.Symbol("outer:with:"), Primitive { CombineOuter })
+    }
+
     RangeNs.Set(vm.Symbol("from"), Primitive { RangeFrom })
     RangeNs.Set(vm.Symbol("to"), Primitive { RangeTo })
     RangeNs.Set(vm.Symbol("min"), Primitive { RangeMin })
@@ -810,6 +1321,39 @@ func PreInitializeCore(vm *TenoriteVM) {
     RangeNs.Set(vm.Symbol("len"), Primitive { RangeLen })
     RangeNs.Set(vm.Symbol("list"), Primitive { RangeList })
     RangeNs.Set(vm.Symbol("next:"), Primitive { RangeNext })
+    RangeNs.Set(vm.Symbol("by:"), Primitive { RangeBy })
+
+    coreMod.Add(vm.Symbol("Seq"), SeqNs)
+    SeqNs.Static = NewNamespace("")
+    SeqNs.Static.Set(vm.Symbol("from:"), Primitive { SeqFrom })
+    SeqNs.Static.Set(vm.Symbol("from:by:"), Primitive { SeqFromBy })
+    for _, ns := range []*Namespace{ SeqNs, RangeNs } {
+        ns.Set(vm.Symbol("<$>"), Primitive { SeqMap })
+        ns.Set(vm.Symbol("<?>"), Primitive { SeqFilter })
+        ns.Set(vm.Symbol("take:"), Primitive { SeqTake })
+        ns.Set(vm.Symbol("drop:"), Primitive { SeqDrop })
+        ns.Set(vm.Symbol("takeWhile:"), Primitive { SeqTakeWhile })
+        ns.Set(vm.Symbol("zip:"), Primitive { SeqZip })
+        ns.Set(vm.Symbol("all"), Primitive { SeqAll })
+        ns.Set(vm.Symbol("any"), Primitive { SeqAny })
+        ns.Set(vm.Symbol("count"), Primitive { SeqCount })
+    }
+    SeqNs.Set(vm.Symbol("list"), Primitive { SeqList })
+    SeqNs.Set(vm.Symbol("len"), Primitive { SeqLen })
+    SeqNs.Set(vm.Symbol("at_:"), Primitive { SeqAt_ })
+    SeqNs.Set(vm.Symbol("next:"), Primitive { SeqNext })
+    SeqNs.Set(vm.Symbol("iterate:"), Primitive { SeqIterate })
+
+    coreMod.Add(vm.Symbol("Coroutine"), CoroutineNs)
+    CoroutineNs.Static = NewNamespace("")
+    CoroutineNs.Static.Set(vm.Symbol("new:"), Primitive { CoroutineNew })
+    CoroutineNs.Set(vm.Symbol("next"), Primitive { CoroutineNext })
+    CoroutineNs.Set(vm.Symbol("isDone"), Primitive { CoroutineIsDone })
+    CoroutineNs.Set(SYM_NEXT, Primitive { CoroutineNext_ })
+    CoroutineNs.Set(SYM_ITERATE, Primitive { SeqIterate })
+    for _, ns := range []*Namespace{ SeqNs, RangeNs, ListNs, ArrayNs, TableNs, SetNs, StringNs, FunctionNs, CoroutineNs } {
+        ns.Set(vm.Symbol("seq"), Primitive { SeqSeq })
+    }
 
     RegexNs.Static = RegexNs
     coreMod.Add(vm.Symbol("RegexResults"), RegexResultsNs)
@@ -823,6 +1367,22 @@ func PreInitializeCore(vm *TenoriteVM) {
     SystemNs.Set(vm.Symbol("assert:"), Primitive { SystemAssert })
     SystemNs.Set(vm.Symbol("panic:"), Primitive { SystemPanic })
     SystemNs.Set(vm.Symbol("writeString:"), Primitive { SystemWriteString })
+    SystemNs.Set(vm.Symbol("raise:"), Primitive { SystemRaise })
+    SystemNs.Set(vm.Symbol("try:catch:"), Primitive { SystemTryCatch })
+    SystemNs.Set(vm.Symbol("try:ensure:"), Primitive { SystemTryEnsure })
+    SystemNs.Set(vm.Symbol("try:catch:ensure:"), Primitive { SystemTryCatchEnsure })
+
+    ErrorNs.Static = ErrorNs
+    coreMod.Add(vm.Symbol("Error"), ErrorNs)
+    ErrorNs.Set(vm.Symbol("new:"), Primitive { ErrorNew })
+    ErrorNs.Set(vm.Symbol("new:kind:"), Primitive { ErrorNewKind })
+    ErrorNs.Set(vm.Symbol("message"), Primitive { ErrorMessage })
+    ErrorNs.Set(vm.Symbol("kind"), Primitive { ErrorKind })
+    ErrorNs.Set(vm.Symbol("file"), Primitive { ErrorFile })
+    ErrorNs.Set(vm.Symbol("line"), Primitive { ErrorLine })
+    ErrorNs.Set(vm.Symbol("column"), Primitive { ErrorColumn })
+    ErrorNs.Set(vm.Symbol("trace"), Primitive { ErrorTrace })
+    ErrorNs.Set(vm.Symbol("value"), Primitive { ErrorValue })
 
 
     ReflectNs := NewNamespace("Reflect")
@@ -0,0 +1,140 @@
+package interpreter
+
+// A Coroutine runs a block on Task stacks of its own. A yield suspends the
+// frames of the block, from the one that yields up to the block's own, and
+// next resumes them where they stood. The frames have to call each other
+// straight, a yield inside a block that a primitive runs, like one given to
+// do:, cannot be suspended and raises an error instead.
+type Coroutine struct {
+    Body       Receiver
+    Done       bool
+    running    bool
+    suspended  *Frame
+    value      Receiver
+    yielding   bool
+    calling    bool
+    barriers   int
+}
+
+var CoroutineNs = NewNamespace("Coroutine")
+
+func (_ *Coroutine) GetMethod(sym Symbol) (meth Receiver) {
+    meth = CoroutineNs.Get(sym); if meth != nil { return }
+    return ObjectNs.Get(sym)
+}
+func (_ *Coroutine) Type(r Receiver) bool { return r == CoroutineNs || r == ObjectNs }
+
+// Resumes the block until its next yield. Answers the yielded value, or
+// None once the block has returned.
+func (co *Coroutine) next(vm *TenoriteVM) (result Receiver, err error) {
+    if co.Done { return NONE, nil }
+    if co.running {
+        return nil, kindError("coroutine", "Cannot resume a coroutine that is already running")
+    }
+
+    caller := vm.Coroutine
+    vm.Coroutine, co.running = co, true
+    suspended := co.suspended
+    co.suspended = nil
+    defer func() {
+        vm.Coroutine, co.running, co.calling = caller, false, false
+        if !co.yielding {
+            co.Done = true
+            return
+        }
+        recover()
+        co.yielding = false
+        result, err = co.value, nil
+    }()
+
+    if suspended == nil {
+        co.calling = true
+        _, err = Run(vm, co.Body, []Receiver{ co.Body })
+    } else {
+        _, err = runFrame(vm, suspended, true)
+    }
+    return NONE, err
+}
+
+func (vm *TenoriteVM) yield(value Receiver) error {
+    co := vm.Coroutine
+    if co == nil {
+        return kindError("coroutine", "Cannot yield outside of a coroutine")
+    }
+    // The primitive in the way may be the try: that would catch the error,
+    // so it cannot be caught.
+    if co.barriers > 0 {
+        return &ErrorObj { Message: "Cannot yield from a block run by a primitive", Kind: "coroutine", Value: NONE, fatal: true }
+    }
+    co.value, co.yielding = value, true
+    vm.Raised = nil
+    panic(co)
+}
+
+func (vm *TenoriteVM) suspending() bool {
+    return vm.Coroutine != nil && vm.Coroutine.yielding
+}
+
+// A call instruction marks the frame it enters next as called straight from
+// the running one, anything else in between clears the mark.
+func (vm *TenoriteVM) callFrame() {
+    if vm.Coroutine != nil { vm.Coroutine.calling = true }
+}
+
+func (vm *TenoriteVM) leaveFrame() {
+    if vm.Coroutine != nil { vm.Coroutine.calling = false }
+}
+
+func (vm *TenoriteVM) enterFrame() bool {
+    if vm.Coroutine == nil { return false }
+    direct := vm.Coroutine.calling
+    vm.Coroutine.calling = false
+    return direct
+}
+
+// Every pass over the seq runs the block afresh in a coroutine of its own,
+// the receiver is left where it stands.
+func coroutineSeq(co *Coroutine) *Seq {
+    return &Seq { func() seqStep {
+        pass := &Coroutine { Body: co.Body }
+        return func(vm *TenoriteVM) (Receiver, bool, error) {
+            value, err := pass.next(vm)
+            return value, err == nil && !pass.Done, err
+        }
+    } }
+}
+
+func CoroutineNew(vm *TenoriteVM, args []Receiver) Receiver {
+    switch args[1].(type) {
+    case *Closure, Primitive:
+    default:
+        vm.Error = kindError("type", "Argument must be a function")
+        return nil
+    }
+    return &Coroutine { Body: args[1] }
+}
+
+func CoroutineNext(vm *TenoriteVM, args []Receiver) Receiver {
+    value, err := args[0].(*Coroutine).next(vm)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return value
+}
+
+func CoroutineIsDone(vm *TenoriteVM, args []Receiver) Receiver {
+    return toBool(args[0].(*Coroutine).Done)
+}
+
+// A for loop over a coroutine resumes it for every item, until it returns.
+func CoroutineNext_(vm *TenoriteVM, args []Receiver) Receiver {
+    co := args[0].(*Coroutine)
+    value, err := co.next(vm)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    if co.Done { return NONE }
+    return &seqCursor { item: value }
+}
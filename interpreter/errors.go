@@ -0,0 +1,217 @@
+package interpreter
+
+import (
+    "errors"
+    "fmt"
+    "runtime"
+    "strings"
+)
+
+// A KindError is a failure that knows which kind of error object it becomes
+// once it reaches the language.
+type KindError struct {
+    Kind     string
+    Message  string
+}
+
+func (e KindError) Error() string { return e.Message }
+
+func kindError(kind string, format string, args ...any) error {
+    return KindError { kind, fmt.Sprintf(format, args...) }
+}
+
+// An ErrorObj is located at the file and line of the frame that raised it.
+type ErrorObj struct {
+    Message  string
+    Kind     string
+    File     string
+    Line     int
+    Column   int
+    Trace    []string
+    Value    Receiver
+    traced   bool
+    fatal    bool
+}
+
+func (e *ErrorObj) Error() string {
+    var result strings.Builder
+    if e.File != "" {
+        fmt.Fprintf(&result, "%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+    } else if e.Column != 0 {
+        fmt.Fprintf(&result, "line %d:%d: %s", e.Line, e.Column, e.Message)
+    } else {
+        fmt.Fprintf(&result, "line %d: %s", e.Line, e.Message)
+    }
+    for _, frame := range e.Trace {
+        result.WriteString("\n    in ")
+        result.WriteString(frame)
+    }
+    return result.String()
+}
+
+func (_ *ErrorObj) GetMethod(sym Symbol) (meth Receiver) {
+    meth = ErrorNs.Get(sym); if meth != nil { return }
+    return ObjectNs.Get(sym)
+}
+func (_ *ErrorObj) Type(r Receiver) bool { return r == ErrorNs || r == ObjectNs }
+
+func errorObject(err error) *ErrorObj {
+    var obj *ErrorObj
+    if errors.As(err, &obj) { return obj }
+
+    kind := "error"
+    var kerr KindError
+    if errors.As(err, &kerr) { kind = kerr.Kind }
+    return &ErrorObj { Message: err.Error(), Kind: kind, Value: NONE }
+}
+
+func traceEntry(codeObj *CodeObj, ip int) string {
+    if span, ok := codeObj.SpanAt(ip); ok {
+        return fmt.Sprintf("%s line %d:%d", codeObj.Name, span.Line, span.Column)
+    }
+    return fmt.Sprintf("%s line %d", codeObj.Name, getLine(ip, codeObj.Lines))
+}
+
+// Runs block, turning a raised error or a Go runtime failure into an error
+// object. Anything else, like a non-local return or a fatal error, keeps
+// unwinding.
+func tryRun(vm *TenoriteVM, block Receiver) (result Receiver, thrown *ErrorObj) {
+    defer func() {
+        r := recover()
+        if r == nil { return }
+
+        switch r := r.(type) {
+        case *ErrorObj:
+            if r.fatal { panic(r) }
+            thrown = r
+            thrown.traced = true
+        case runtime.Error:
+            thrown = &ErrorObj { Message: r.Error(), Kind: "internal", Value: NONE }
+        default:
+            panic(r)
+        }
+        vm.Raised = nil
+    }()
+
+    result, err := Run(vm, block, []Receiver { block })
+    if err != nil {
+        thrown = errorObject(err)
+        if thrown.fatal { panic(thrown) }
+        thrown.traced = true
+        return nil, thrown
+    }
+    return result, nil
+}
+
+func SystemTryCatch(vm *TenoriteVM, args []Receiver) Receiver {
+    result, thrown := tryRun(vm, args[1])
+    if thrown == nil { return result }
+
+    result, err := Run(vm, args[2], []Receiver { args[2], thrown })
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
+// Runs body and then the ensure block, also when body fails. The ensure
+// block's error is raised in place of the result, unless body failed first
+// and keeps its own error.
+func runEnsure(vm *TenoriteVM, body func() Receiver, block Receiver) Receiver {
+    done := false
+    defer func() {
+        if done { return }
+        raised := vm.Raised
+        tryRun(vm, block)
+        vm.Raised = raised
+    }()
+
+    result := body()
+    done = true
+    failure := vm.Error
+    _, thrown := tryRun(vm, block)
+    if result == nil {
+        vm.Error = failure
+        return nil
+    }
+    if thrown != nil {
+        vm.Error = thrown
+        return nil
+    }
+    return result
+}
+
+func SystemTryEnsure(vm *TenoriteVM, args []Receiver) Receiver {
+    return runEnsure(vm, func() Receiver {
+        result, err := Run(vm, args[1], []Receiver { args[1] })
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        return result
+    }, args[2])
+}
+
+func SystemTryCatchEnsure(vm *TenoriteVM, args []Receiver) Receiver {
+    return runEnsure(vm, func() Receiver {
+        return SystemTryCatch(vm, args[:3])
+    }, args[3])
+}
+
+func SystemRaise(vm *TenoriteVM, args []Receiver) Receiver {
+    if obj, ok := args[1].(*ErrorObj); ok {
+        vm.Error = obj
+        return nil
+    }
+    vm.Error = &ErrorObj { Message: toString(vm, args[1]), Kind: "raise", Value: args[1] }
+    return nil
+}
+
+func ErrorNew(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateString(vm, args[1], "Message") { return nil }
+    return &ErrorObj { Message: string(args[1].(String)), Kind: "error", Value: NONE }
+}
+
+func ErrorNewKind(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateString(vm, args[1], "Message") { return nil }
+    kind, ok := args[2].(Symbol)
+    if !ok {
+        vm.Error = kindError("type", "Kind must be a symbol.")
+        return nil
+    }
+    return &ErrorObj { Message: string(args[1].(String)), Kind: vm.SymbolStore[kind], Value: NONE }
+}
+
+func ErrorMessage(vm *TenoriteVM, args []Receiver) Receiver {
+    return String(args[0].(*ErrorObj).Message)
+}
+
+func ErrorKind(vm *TenoriteVM, args []Receiver) Receiver {
+    return vm.Symbol(args[0].(*ErrorObj).Kind)
+}
+
+func ErrorFile(vm *TenoriteVM, args []Receiver) Receiver {
+    return String(args[0].(*ErrorObj).File)
+}
+
+func ErrorLine(vm *TenoriteVM, args []Receiver) Receiver {
+    return Number(args[0].(*ErrorObj).Line)
+}
+
+func ErrorColumn(vm *TenoriteVM, args []Receiver) Receiver {
+    return Number(args[0].(*ErrorObj).Column)
+}
+
+func ErrorTrace(vm *TenoriteVM, args []Receiver) Receiver {
+    trace := args[0].(*ErrorObj).Trace
+    list := make([]Receiver, len(trace))
+    for i, frame := range trace {
+        list[i] = String(frame)
+    }
+    return List { list }
+}
+
+func ErrorValue(vm *TenoriteVM, args []Receiver) Receiver {
+    return args[0].(*ErrorObj).Value
+}
@@ -0,0 +1,173 @@
+package interpreter
+
+import "math"
+
+// Reductions walk the items of a collection with a Symbol or a block of two
+// arguments, left to right or right to left. A Symbol with an identity element
+// reduces an empty collection to it, so `[] </> #+` is 0 and `[] </> #>>` is
+// negative infinity.
+
+var (
+    SYM_AND = makeSymbol("and:")
+    SYM_OR = makeSymbol("or:")
+)
+
+func identityOf(f Receiver) (Receiver, bool) {
+    sym, ok := f.(Symbol)
+    if !ok { return nil, false }
+    switch sym {
+    case SYM_ADD: return Number(0), true
+    case SYM_MUL: return Number(1), true
+    case SYM_AND: return TRUE, true
+    case SYM_OR: return FALSE, true
+    case SYM_MAX: return Float(math.Inf(-1)), true
+    case SYM_MIN: return Float(math.Inf(1)), true
+    }
+    return nil, false
+}
+
+// Applies f to a and b, numbers under the arithmetic symbols are combined
+// without a message send.
+func apply(vm *TenoriteVM, f, a, b Receiver) (Receiver, error) {
+    sym, isSymbol := f.(Symbol)
+    if isSymbol && isNumeric(a) && isNumeric(b) {
+        switch sym {
+        case SYM_ADD: return numArith('+', a, b)
+        case SYM_SUB: return numArith('-', a, b)
+        case SYM_MUL: return numArith('*', a, b)
+        case SYM_MAX:
+            if c, _ := numCompare(b, a); c > 0 { return b, nil }
+            return a, nil
+        case SYM_MIN:
+            if c, _ := numCompare(b, a); c < 0 { return b, nil }
+            return a, nil
+        }
+    }
+    return combine(vm, f, nil, a, b)
+}
+
+func errEmptyReduce(vm *TenoriteVM, f Receiver) error {
+    if sym, ok := f.(Symbol); ok {
+        return kindError("size", "Cannot reduce an empty collection, #%s has no identity", vm.SymbolStore[sym])
+    }
+    return kindError("size", "Cannot reduce an empty collection with a block")
+}
+
+func reduce(vm *TenoriteVM, items, f Receiver, fromRight bool) (Receiver, error) {
+    n := Size(items)
+    if n == 0 {
+        if id, ok := identityOf(f); ok { return id, nil }
+        return nil, errEmptyReduce(vm, f)
+    }
+
+    var err error
+    if fromRight {
+        acc := GetAt(items, n-1)
+        for i := n-2; i >= 0 && err == nil; i-- {
+            acc, err = apply(vm, f, GetAt(items, i), acc)
+        }
+        return acc, err
+    }
+    acc := GetAt(items, 0)
+    for i := 1; i < n && err == nil; i++ {
+        acc, err = apply(vm, f, acc, GetAt(items, i))
+    }
+    return acc, err
+}
+
+// Answers the running reductions, the prefixes from the left or the suffixes
+// from the right, shaped like the receiver.
+func scan(vm *TenoriteVM, items, f Receiver, fromRight bool) (Receiver, error) {
+    n := Size(items)
+    results := make([]Receiver, n)
+    if n > 0 {
+        var err error
+        if fromRight {
+            results[n-1] = GetAt(items, n-1)
+            for i := n-2; i >= 0 && err == nil; i-- {
+                results[i], err = apply(vm, f, GetAt(items, i), results[i+1])
+            }
+        } else {
+            results[0] = GetAt(items, 0)
+            for i := 1; i < n && err == nil; i++ {
+                results[i], err = apply(vm, f, results[i-1], GetAt(items, i))
+            }
+        }
+        if err != nil { return nil, err }
+    }
+
+    if tbl, isTable := items.(*Table); isTable {
+        return tbl.withValues(results), nil
+    }
+    return assemble(items, results), nil
+}
+
+func fold(vm *TenoriteVM, items, f, init Receiver, fromRight bool) (Receiver, error) {
+    n := Size(items)
+    acc := init
+    var err error
+    if fromRight {
+        for i := n-1; i >= 0 && err == nil; i-- {
+            acc, err = apply(vm, f, GetAt(items, i), acc)
+        }
+        return acc, err
+    }
+    for i := 0; i < n && err == nil; i++ {
+        acc, err = apply(vm, f, acc, GetAt(items, i))
+    }
+    return acc, err
+}
+
+func SeqReduce(vm *TenoriteVM, args []Receiver) Receiver {
+    result, err := reduce(vm, args[0], args[1], false)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
+func SeqReduceRight(vm *TenoriteVM, args []Receiver) Receiver {
+    result, err := reduce(vm, args[0], args[1], true)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
+func SeqScan(vm *TenoriteVM, args []Receiver) Receiver {
+    result, err := scan(vm, args[0], args[1], false)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
+func SeqScanRight(vm *TenoriteVM, args []Receiver) Receiver {
+    result, err := scan(vm, args[0], args[1], true)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
+func SeqFold(vm *TenoriteVM, args []Receiver) Receiver {
+    result, err := fold(vm, args[0], args[1], args[2], false)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
+func SeqFoldRight(vm *TenoriteVM, args []Receiver) Receiver {
+    result, err := fold(vm, args[0], args[1], args[2], true)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
@@ -2,6 +2,7 @@ package interpreter
 
 import (
     "fmt"
+    "sort"
 )
 
 type CodeObj struct {
@@ -12,13 +13,33 @@ type CodeObj struct {
     LocalSize     uint16
     UpvalueCount  uint16
     Name          string
+    File          string
+    IsBlock       bool
+    CatchesReturn bool
 
     BaseLine      int
     Lines         []int
+    Spans         []SourceSpan
     CoVarnames    []string
     DebugMap      map[uint16]string
 }
 
+type SourceSpan struct {
+    Ip         int
+    Line       int
+    Column     int
+    EndLine    int
+    EndColumn  int
+}
+
+func (co *CodeObj) SpanAt(ip int) (SourceSpan, bool) {
+    i := sort.Search(len(co.Spans), func(i int) bool { return co.Spans[i].Ip >= ip })
+    if i < len(co.Spans) && co.Spans[i].Ip == ip {
+        return co.Spans[i], true
+    }
+    return SourceSpan {}, false
+}
+
 type Upvalue struct {
     Value   *Receiver
     Slot    uint16
@@ -29,6 +50,18 @@ type Upvalue struct {
 type Closure struct {
     CodeObj       *CodeObj
     Upvalues      []*Upvalue
+    Home          *Activation
+}
+
+// An Activation is a running method that blocks created inside it can
+// return from.
+type Activation struct {
+    Done  bool
+}
+
+type NonLocalReturn struct {
+    Home   *Activation
+    Value  Receiver
 }
 
 func (c Closure) String() string {
@@ -13,9 +13,11 @@ func Run(vm *TenoriteVM, callable Receiver, args []Receiver) (Receiver, error) {
     case Primitive:
         result := sub.Call(vm, args)
         if result == nil {
-            return nil, fmt.Errorf("%v %s",
-                runtime.FuncForPC(reflect.ValueOf(sub.Call).Pointer()).Name(),
-                vm.Error)
+            if vm.Error == nil {
+                return nil, fmt.Errorf("%v answered no value",
+                    runtime.FuncForPC(reflect.ValueOf(sub.Call).Pointer()).Name())
+            }
+            return nil, vm.Error
         }
         return result, nil
     }
@@ -32,20 +34,82 @@ func getLine(ip int, lines []int) (line int) {
 }
 
 func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error) {
-    code := sub.CodeObj.Code
-    ip := 0
+    home := sub.Home
+    if sub.CodeObj.CatchesReturn {
+        home = &Activation {}
+    }
+    return runFrame(vm, newFrame(sub, args, home), vm.enterFrame())
+}
+
+// Runs fr, catching the non-local returns to it when it is the frame of a
+// method.
+func runFrame(vm *TenoriteVM, fr *Frame, direct bool) (Receiver, error) {
+    if fr.Sub.CodeObj.CatchesReturn {
+        return runHome(vm, fr, direct)
+    }
+    return runClosure(vm, fr, direct)
+}
 
-    task := Task {}
+func runHome(vm *TenoriteVM, fr *Frame, direct bool) (result Receiver, err error) {
+    activation := fr.Home
+    defer func() {
+        if vm.suspending() { return }
+        activation.Done = true
+        if r := recover(); r != nil {
+            nlr, ok := r.(NonLocalReturn)
+            if !ok || nlr.Home != activation { panic(r) }
+            result, err = nlr.Value, nil
+        }
+    }()
+    return runClosure(vm, fr, direct)
+}
 
-    locals := make([]Receiver, int(sub.CodeObj.LocalSize)+len(args))
-    for i, arg := range args {
-        locals[i] = arg
+// Runs fr from where it stands. A frame that was suspended inside a call
+// first resumes its callee and continues with the callee's result.
+func runClosure(vm *TenoriteVM, fr *Frame, direct bool) (result Receiver, err error) {
+    sub, home := fr.Sub, fr.Home
+    code := sub.CodeObj.Code
+    ip, debugIp := fr.Ip, fr.DebugIp
+    task, locals := fr.Task, fr.Locals
+    callFp, tail := fr.CallFp, fr.Tail
+
+    // A frame of a coroutine that is not called straight from another of
+    // its frames cannot be suspended, the Go code between them holds state.
+    co := vm.Coroutine
+    if co != nil && !direct {
+        co.barriers++
+        defer func() { co.barriers-- }()
     }
 
-    task.Push(locals[0])
+    // A nil result means the frame is being unwound by a panic. An error
+    // raised again after it was caught keeps the trace it was caught with.
+    // A yield unwinds the frames of its coroutine, and each of them keeps
+    // its state to be resumed from.
+    defer func() {
+        if co != nil && co.yielding {
+            *fr = Frame { sub, home, ip, debugIp, task, locals, co.suspended, callFp, tail }
+            co.suspended = fr
+            return
+        }
+        if result == nil && vm.Raised != nil && !vm.Raised.traced {
+            vm.Raised.Trace = append(vm.Raised.Trace, traceEntry(sub.CodeObj, debugIp))
+        }
+    }()
+
+    if callee := fr.Callee; callee != nil {
+        fr.Callee = nil
+        result, err := runFrame(vm, callee, true)
+        if err != nil {
+            task.Error = err
+            task.Panic(vm, debugIp, sub.CodeObj)
+        }
+        if tail { return result, nil }
+        task.Stack = task.Stack[:callFp]
+        task.Push(result)
+    }
 
     for {
-        debugIp := ip
+        debugIp = ip
         debugName := sub.CodeObj.Name
         debugLines := sub.CodeObj.Lines
 
@@ -62,7 +126,8 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
         case OP_CLOSURE:
             task.Push(NONE)
             codeObj := sub.CodeObj.Consts[code[ip+1]].(*CodeObj)
-            closure := &Closure{ codeObj, make([]*Upvalue, codeObj.UpvalueCount) }
+            closure := &Closure{ codeObj, make([]*Upvalue, codeObj.UpvalueCount), nil }
+            if codeObj.IsBlock { closure.Home = home }
             ip++
             for i := uint16(0); i < codeObj.UpvalueCount; i++ {
                 ip++
@@ -92,8 +157,12 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
             name := Symbol(code[ip+1])
             loc, ok := vm.TopModule.Table[name]
             if !ok {
-                task.Error = fmt.Errorf("Undefined Name #%s", vm.SymbolStore[name])
-                task.Panic(ip, debugLines)
+                task.Error = kindError("name", "Undefined Name #%s", vm.SymbolStore[name])
+                task.Panic(vm, debugIp, sub.CodeObj)
+            }
+            if vm.TopModule.Variables[loc] == nil {
+                task.Error = kindError("name", "Name #%s used before its definition", vm.SymbolStore[name])
+                task.Panic(vm, debugIp, sub.CodeObj)
             }
             task.Push(vm.TopModule.Variables[loc])
             ip+=2
@@ -121,12 +190,12 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
             obj, ok := locals[0].(Object)
             if !ok {
                 task.Error = fmt.Errorf("Invalid field `%s´ access", vm.SymbolStore[name])
-                task.Panic(ip, debugLines)
+                task.Panic(vm, debugIp, sub.CodeObj)
             }
             result := obj.Table[name]
             if result == nil {
                 task.Error = fmt.Errorf("Invalid field `%s´ access", vm.SymbolStore[name])
-                task.Panic(ip, debugLines)
+                task.Panic(vm, debugIp, sub.CodeObj)
             }
             task.Push(result)
             ip+=2
@@ -135,7 +204,7 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
             obj, ok := locals[0].(Object)
             if !ok {
                 task.Error = fmt.Errorf("Invalid field access")
-                task.Panic(ip, debugLines)
+                task.Panic(vm, debugIp, sub.CodeObj)
             }
             obj.Table[name] = task.Stack[len(task.Stack)-1]
             ip+=2
@@ -144,7 +213,7 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
             op, ok := vm.Operators[name]
             if !ok {
                 task.Error = fmt.Errorf("Undefined Operator #%s", vm.SymbolStore[name])
-                task.Panic(ip, debugLines)
+                task.Panic(vm, debugIp, sub.CodeObj)
             }
             b := task.Pop()
             a := task.Pop()
@@ -169,6 +238,30 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
             } else {
                 ip+=2
             }
+        case OP_ITERATE:
+            at := code[ip+1]
+            seq := locals[at]
+            next, err := Call(vm, Message { SYM_NEXT, make([]int, 2) }, []Receiver{ seq, locals[at+1] })
+            if err != nil {
+                task.Error = err
+                task.Panic(vm, debugIp, sub.CodeObj)
+            }
+            if _, isNone := next.(None); isNone {
+                task.Push(FALSE)
+                ip+=2
+                break
+            }
+            locals[at+1] = next
+            value, err := Call(vm, Message { SYM_ITERATE, make([]int, 2) }, []Receiver{ seq, next })
+            if err != nil {
+                task.Error = err
+                task.Panic(vm, debugIp, sub.CodeObj)
+            }
+            task.Push(value)
+            task.Push(TRUE)
+            ip+=2
+        case OP_JUMP:
+            ip+=int(code[ip+1])
         case OP_CALL_R:
             nargs := int(code[ip+1])+1
             fp := len(task.Stack)-(nargs+1)
@@ -178,14 +271,16 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
             msg := Message { sym, make([]int, nargs) }
 
             for i := 0; i < nargs; i++ {
-                msg.Ranks[i] = int(code[ip])
+                msg.Ranks[i] = int(int16(code[ip]))
                 ip++
             }
 
+            callFp, tail = fp, false
+            vm.callFrame()
             result, err := Call(vm, msg, task.Stack[fp:])
             if err != nil {
                 task.Error = err
-                task.Panic(ip, debugLines)
+                task.Panic(vm, debugIp, sub.CodeObj)
             }
 
             task.Stack = task.Stack[:fp]
@@ -196,12 +291,12 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
             ip+=1
 
             sym := task.Pop().(Symbol)
-            msg := Message { sym, []int{0, 1} }
+            msg := Message { sym, []int{0, -1} }
             
             result, err := Call(vm, msg, task.Stack[fp:])
             if err != nil {
                 task.Error = err
-                task.Panic(ip, debugLines)
+                task.Panic(vm, debugIp, sub.CodeObj)
             }
 
             task.Stack = task.Stack[:fp]
@@ -214,14 +309,48 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
             sym := task.Pop().(Symbol)
             msg := Message { sym, make([]int, nargs) }
 
+            callFp, tail = fp, false
+            vm.callFrame()
             result, err := Call(vm, msg, task.Stack[fp:])
             if err != nil {
                 task.Error = err
-                task.Panic(ip, debugLines)
+                task.Panic(vm, debugIp, sub.CodeObj)
             }
 
             task.Stack = task.Stack[:fp]
             task.Push(result)
+        case OP_TAIL_CALL:
+            nargs := int(code[ip+1])+1
+            fp := len(task.Stack)-(nargs+1)
+            ip+=2
+
+            sym := task.Pop().(Symbol)
+            callArgs := task.Stack[fp:]
+
+            closure := tailCallTarget(sym, callArgs)
+            if closure == nil || sub.CodeObj.CatchesReturn || closure.CodeObj.CatchesReturn {
+                callFp, tail = fp, true
+                vm.callFrame()
+                result, err := Call(vm, Message { sym, make([]int, nargs) }, callArgs)
+                if err != nil {
+                    task.Error = err
+                    task.Panic(vm, debugIp, sub.CodeObj)
+                }
+                return result, nil
+            }
+
+            closeUpvalue(&task, locals, 0)
+
+            sub = closure
+            code = sub.CodeObj.Code
+            ip = 0
+            home = sub.Home
+
+            locals = make([]Receiver, int(sub.CodeObj.LocalSize)+len(callArgs))
+            copy(locals, callArgs)
+
+            task.Stack = task.Stack[:0]
+            task.Push(locals[0])
         case OP_POP:
             task.Pop()
             ip+=1
@@ -238,6 +367,21 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
         case OP_RETURN:
             result := task.Pop()
             return result, nil
+        case OP_NONLOCAL_RETURN:
+            result := task.Pop()
+            if home == nil || home.Done {
+                task.Error = kindError("return", "Non-local return from a method that has already returned")
+                task.Panic(vm, debugIp, sub.CodeObj)
+            }
+            vm.Raised = nil
+            panic(NonLocalReturn { home, result })
+        case OP_YIELD:
+            value := task.Pop()
+            ip+=1
+            callFp, tail = -1, false
+            err := vm.yield(value)
+            task.Error = err
+            task.Panic(vm, debugIp, sub.CodeObj)
         case OP_TYPE:
             ns := task.Pop()
             obj := task.Pop()
@@ -251,8 +395,109 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
                 val := task.Pop()
                 list[n-i-1] = val
             }
-            task.Push(List { list })
+            task.Push(packList(list))
             ip++
+        case OP_MAKE_RECORD:
+            n := int(code[ip+1])
+            fields := make([]Symbol, n)
+            table := make(map[Symbol]Receiver, n)
+            base := len(task.Stack)-2*n
+            for i := 0; i < n; i++ {
+                fields[i] = task.Stack[base+2*i].(Symbol)
+                table[fields[i]] = task.Stack[base+2*i+1]
+            }
+            task.Stack = task.Stack[:base]
+            task.Push(Object { Roles: []*Namespace{RecordNs}, Table: table, Fields: fields })
+            ip+=2
+        case OP_UNPACK_LIST:
+            n := int(code[ip+1])
+            rest := code[ip+2] != 0
+            list, ok := asList(task.Pop())
+            if !ok {
+                task.Error = kindError("pattern", "Cannot destructure a non-list value into a list pattern")
+                task.Panic(vm, debugIp, sub.CodeObj)
+            }
+            if len(list.List) != n && !(rest && len(list.List) > n) {
+                if rest {
+                    task.Error = kindError("pattern", "Cannot destructure a list of length %d into at least %d elements", len(list.List), n)
+                } else {
+                    task.Error = kindError("pattern", "Cannot destructure a list of length %d into %d elements", len(list.List), n)
+                }
+                task.Panic(vm, debugIp, sub.CodeObj)
+            }
+            task.unpackList(list, n, rest)
+            ip+=3
+        case OP_UNPACK_PAIR:
+            pair, ok := task.Pop().(Pair)
+            if !ok {
+                task.Error = kindError("pattern", "Cannot destructure a non-pair value into a pair pattern")
+                task.Panic(vm, debugIp, sub.CodeObj)
+            }
+            task.Push(pair.Second)
+            task.Push(pair.First)
+            ip+=1
+        case OP_UNPACK_KEY:
+            key := task.Pop()
+            table, ok := task.Stack[len(task.Stack)-1].(*Table)
+            if !ok {
+                task.Error = kindError("pattern", "Cannot destructure a non-table value into a table pattern")
+                task.Panic(vm, debugIp, sub.CodeObj)
+            }
+            value, found, err := tableLookup(vm, table, key)
+            if err != nil {
+                task.Error = err
+                task.Panic(vm, debugIp, sub.CodeObj)
+            }
+            if !found {
+                task.Error = kindError("pattern", "Cannot destructure a table of size %d, missing key %s", len(table.Keys), toDebugString(vm, key))
+                task.Panic(vm, debugIp, sub.CodeObj)
+            }
+            task.Push(value)
+            ip+=1
+        case OP_MATCH_LIST:
+            n := int(code[ip+1])
+            rest := code[ip+2] != 0
+            list, ok := asList(task.Pop())
+            if ok && (len(list.List) == n || rest && len(list.List) > n) {
+                task.unpackList(list, n, rest)
+                task.Push(TRUE)
+            } else {
+                task.Push(FALSE)
+            }
+            ip+=3
+        case OP_MATCH_PAIR:
+            if pair, ok := task.Pop().(Pair); ok {
+                task.Push(pair.Second)
+                task.Push(pair.First)
+                task.Push(TRUE)
+            } else {
+                task.Push(FALSE)
+            }
+            ip+=1
+        case OP_MATCH_REGEX:
+            regex := task.Pop().(Regex)
+            subject, ok := task.Pop().(String)
+            if !ok {
+                task.Push(FALSE)
+                ip+=1
+                continue
+            }
+            groups := regex.Regex.FindStringSubmatch(string(subject))
+            if groups == nil {
+                task.Push(FALSE)
+                ip+=1
+                continue
+            }
+            list := make([]Receiver, len(groups))
+            for i, group := range groups {
+                list[i] = String(group)
+            }
+            task.Push(List { list })
+            task.Push(TRUE)
+            ip+=1
+        case OP_NO_MATCH:
+            task.Error = kindError("pattern", "No pattern matched %s", toDebugString(vm, task.Pop()))
+            task.Panic(vm, debugIp, sub.CodeObj)
         case OP_MAKE_NS:
             name := task.Pop().(String)
             ns := NewNamespace(string(name))
@@ -266,7 +511,7 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
                 ns.Table[symbol] = subroutine
             } else {
                 task.Error = fmt.Errorf("Object not a namespace %v", obj)
-                task.Panic(ip, debugLines)
+                task.Panic(vm, debugIp, sub.CodeObj)
             }
             task.Push(obj)
             ip+=2
@@ -281,26 +526,25 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
                 ns.Static.Table[symbol] = subroutine
             } else {
                 task.Error = fmt.Errorf("Object not a namespace %v", obj)
-                task.Panic(ip, debugLines)
+                task.Panic(vm, debugIp, sub.CodeObj)
             }
             task.Push(obj)
             ip+=2
-        /*case OP_MAKE_TABLE:
-            ip++
-            n := code[ip]
-            table := make(map[Receiver]Receiver, n)
-            for i := uint16(0); i < n; i++ {
-                val := task.Pop()
-                key := task.Pop()
-                if !isHashable(key) {
-                    task.Error = fmt.Errorf("Invalid type for table key")
-                    task.Panic(ip)
+        case OP_MAKE_TABLE:
+            n := int(code[ip+1])
+            base := len(task.Stack)-2*n
+            table := &Table{}
+            for i := base; i < len(task.Stack); i += 2 {
+                err := tableSet(vm, table, task.Stack[i], task.Stack[i+1])
+                if err != nil {
+                    task.Error = err
+                    task.Panic(vm, debugIp, sub.CodeObj)
                 }
-                table[key] = val
             }
-            task.Push(Table { table, nil })
-            ip++
-        case OP_MAKE_CONS:
+            task.Stack = task.Stack[:base]
+            task.Push(table)
+            ip+=2
+        /*case OP_MAKE_CONS:
             subroutine := task.Pop().(*Closure)
             symbol := Symbol(code[ip+1])
             ns := task.Pop().Namespace()
@@ -317,7 +561,7 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
             ip = 0
         default:
             task.Error = fmt.Errorf("Invalid Opcode %s", OPCODE_NAMES[op])
-            task.Panic(ip, debugLines)
+            task.Panic(vm, debugIp, sub.CodeObj)
             return nil, nil
         }
 
@@ -336,6 +580,33 @@ func RunClosure(vm *TenoriteVM, sub *Closure, args []Receiver) (Receiver, error)
 }
 
 
+// The selectors FunctionCall is registered under, a tail send of one of them
+// to a Closure runs the Closure in the sender's frame.
+var callSelectors = map[Symbol]bool{}
+
+func isFunctionCall(sym Symbol, method Receiver) bool {
+    _, ok := method.(Primitive)
+    return ok && callSelectors[sym]
+}
+
+// Whether method runs a Closure straight away, being one or calling one.
+func callsClosure(sym Symbol, method Receiver, recv Receiver) bool {
+    if _, ok := method.(*Closure); ok { return true }
+    _, ok := recv.(*Closure)
+    return ok && isFunctionCall(sym, method)
+}
+
+func tailCallTarget(sym Symbol, args []Receiver) *Closure {
+    method := args[0].GetMethod(sym)
+    if closure, ok := method.(*Closure); ok {
+        return closure
+    }
+    if closure, ok := args[0].(*Closure); ok && isFunctionCall(sym, method) {
+        return closure
+    }
+    return nil
+}
+
 func captureUpvalue(task *Task, locals []Receiver, slot uint16) *Upvalue {
     var prevUpvalue *Upvalue
     var upvalue *Upvalue = task.OpenUpvalues
@@ -379,6 +650,29 @@ type Task struct {
     OpenUpvalues  *Upvalue
 }
 
+// The state of a running Closure. A coroutine keeps the frames a yield
+// suspended, each one waiting on the call to its Callee at CallFp, or on a
+// tail call that answers the Callee's result.
+type Frame struct {
+    Sub           *Closure
+    Home          *Activation
+    Ip            int
+    DebugIp       int
+    Task          Task
+    Locals        []Receiver
+    Callee        *Frame
+    CallFp        int
+    Tail          bool
+}
+
+func newFrame(sub *Closure, args []Receiver, home *Activation) *Frame {
+    locals := make([]Receiver, int(sub.CodeObj.LocalSize)+len(args))
+    copy(locals, args)
+    fr := &Frame { Sub: sub, Home: home, Locals: locals, CallFp: -1 }
+    fr.Task.Push(locals[0])
+    return fr
+}
+
 func (task *Task) Push(value Receiver)  {
     task.Stack = append(task.Stack, value)
 }
@@ -389,7 +683,27 @@ func (task *Task) Pop() (value Receiver) {
     return
 }
 
-func (task *Task) Panic(ip int, lines []int) {
-    line := getLine(ip, lines)
-    panic(fmt.Sprintf("line %d: %s", line, task.Error.Error()))
+func (task *Task) unpackList(list List, n int, rest bool) {
+    if rest {
+        tail := make([]Receiver, len(list.List)-n)
+        copy(tail, list.List[n:])
+        task.Push(List { tail })
+    }
+    for i := n-1; i >= 0; i-- {
+        task.Push(list.List[i])
+    }
+}
+
+func (task *Task) Panic(vm *TenoriteVM, ip int, codeObj *CodeObj) {
+    obj := errorObject(task.Error)
+    if obj.Line == 0 {
+        obj.File = codeObj.File
+        if span, ok := codeObj.SpanAt(ip); ok {
+            obj.Line, obj.Column = span.Line, span.Column
+        } else {
+            obj.Line = getLine(ip, codeObj.Lines)
+        }
+    }
+    vm.Raised = obj
+    panic(obj)
 }
\ No newline at end of file
@@ -0,0 +1,251 @@
+package interpreter
+
+import "strings"
+
+// Items compare like Table keys, so numbers are the same item when their
+// values are, 1 and 1.0 included, and Lists are equal when their items are. Lookups over many items build a table of
+// positions once instead of scanning the list for each of them.
+
+var SYM_CONTAINS = makeSymbol("contains:")
+
+// Answers the items of a collection, or the characters of a String.
+func itemsOf(r Receiver) []Receiver {
+    switch r := r.(type) {
+    case List:
+        return r.List
+    case String:
+        chars := strings.Split(string(r), "")
+        items := make([]Receiver, len(chars))
+        for i, char := range chars {
+            items[i] = String(char)
+        }
+        return items
+    }
+    items := make([]Receiver, Size(r))
+    for i := range items {
+        items[i] = GetAt(r, i)
+    }
+    return items
+}
+
+// Answers items in the kind of collection source is, a String joins its
+// characters back together.
+func rebuild(source Receiver, items []Receiver) Receiver {
+    if _, isString := source.(String); isString {
+        var b strings.Builder
+        for _, char := range items {
+            b.WriteString(string(char.(String)))
+        }
+        return String(b.String())
+    }
+    return assemble(source, items)
+}
+
+// Maps every distinct item to the position of its first occurrence.
+func positions(vm *TenoriteVM, items []Receiver) (*Table, error) {
+    table := &Table{}
+    for i, item := range items {
+        at, err := tableFind(vm, table, item)
+        if err != nil { return nil, err }
+        if at != -1 { continue }
+        err = tableSet(vm, table, item, Number(i))
+        if err != nil { return nil, err }
+    }
+    return table, nil
+}
+
+func ListUnique(vm *TenoriteVM, args []Receiver) Receiver {
+    table, err := positions(vm, itemsOf(args[0]))
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return rebuild(args[0], table.Keys)
+}
+
+// Answers True for the first occurrence of every item and False for repeats.
+func ListNubSieve(vm *TenoriteVM, args []Receiver) Receiver {
+    items := itemsOf(args[0])
+    table, err := positions(vm, items)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    sieve := make([]bool, len(items))
+    for _, at := range table.Values {
+        sieve[at.(Number)] = true
+    }
+    return boolList(len(items), func(i int) bool { return sieve[i] })
+}
+
+// Answers the position of the first item equal to the argument, or None. An
+// argument of higher rank than the items is looked up cell by cell.
+func ListIndexOf(vm *TenoriteVM, args []Receiver) Receiver {
+    items := itemsOf(args[0])
+    itemRank := 0
+    if len(items) > 0 { itemRank = len(shapeOf(items[0])) }
+    if len(shapeOf(args[1])) <= itemRank {
+        for i, item := range items {
+            same, err := keyEqual(vm, item, args[1])
+            if err != nil {
+                vm.Error = err
+                return nil
+            }
+            if same { return Number(i) }
+        }
+        return NONE
+    }
+
+    table, err := positions(vm, items)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    keys := itemsOf(args[1])
+    result := make([]Receiver, len(keys))
+    for i, key := range keys {
+        at, found, err := tableLookup(vm, table, key)
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        result[i] = NONE
+        if found { result[i] = at }
+    }
+    return packList(result)
+}
+
+func ListContains(vm *TenoriteVM, args []Receiver) Receiver {
+    for _, item := range itemsOf(args[0]) {
+        same, err := keyEqual(vm, item, args[1])
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        if same { return TRUE }
+    }
+    return FALSE
+}
+
+// Tests every item for membership in the argument, Lists and Sets are looked
+// up by hash and anything else is asked with #contains:.
+func ListMemberOf(vm *TenoriteVM, args []Receiver) Receiver {
+    items := itemsOf(args[0])
+    var table *Table
+    switch seq := args[1].(type) {
+    case Set:
+        table = seq.Members
+    case List, Array:
+        var err error
+        table, err = positions(vm, itemsOf(seq))
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+    default:
+        if seq.GetMethod(SYM_CONTAINS) == nil {
+            vm.Error = kindError("type", "#contains: not found in call to #memberOf:")
+            return nil
+        }
+        result, err := Call(vm, Message { SYM_CONTAINS, []int{ 0, -1 } }, []Receiver{ seq, List { items } })
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        return result
+    }
+
+    result := make([]Receiver, len(items))
+    for i, item := range items {
+        at, err := tableFind(vm, table, item)
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        result[i] = toBool(at != -1)
+    }
+    return List { result }
+}
+
+func flatten(items []Receiver, depth int, flat []Receiver) []Receiver {
+    for _, item := range items {
+        switch item.(type) {
+        case List, Array:
+            if depth != 0 {
+                flat = flatten(itemsOf(item), depth-1, flat)
+                continue
+            }
+        }
+        flat = append(flat, item)
+    }
+    return flat
+}
+
+// Splices nested lists into their parent, all the way down or only depth
+// levels.
+func ListFlat(vm *TenoriteVM, args []Receiver) Receiver {
+    return packList(flatten(itemsOf(args[0]), -1, []Receiver{}))
+}
+
+func ListFlatDepth(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumber(vm, args[1], "Depth") { return nil }
+    depth := int(args[1].(Number))
+    if depth < 0 {
+        vm.Error = kindError("index", "Depth must not be negative, got %d", depth)
+        return nil
+    }
+    return packList(flatten(itemsOf(args[0]), depth, []Receiver{}))
+}
+
+func ListReverse(vm *TenoriteVM, args []Receiver) Receiver {
+    items := itemsOf(args[0])
+    reversed := make([]Receiver, len(items))
+    for i, item := range items {
+        reversed[len(items)-1-i] = item
+    }
+    return rebuild(args[0], reversed)
+}
+
+// Rotates the items by n places, >> towards the end and << towards the
+// start. With a collection on the right they keep their elementwise meaning
+// of maximum and minimum.
+func rotatePrimitive(sym Symbol, sign int) Primitive {
+    return Primitive { func(vm *TenoriteVM, args []Receiver) Receiver {
+        if IsCollection(args[1]) {
+            result, err := Call(vm, Message { sym, []int{ -1, -1 } }, args)
+            if err != nil {
+                vm.Error = err
+                return nil
+            }
+            return result
+        }
+        if !validateNumber(vm, args[1], "Right side") { return nil }
+
+        items := itemsOf(args[0])
+        if len(items) == 0 { return args[0] }
+        rotated := make([]Receiver, len(items))
+        shift := (sign*int(args[1].(Number))) % len(items)
+        for i, item := range items {
+            rotated[(i+shift+len(items)) % len(items)] = item
+        }
+        return assemble(args[0], rotated)
+    } }
+}
+
+// Pairs up the items of both lists, stopping at the end of the shorter one. A
+// String argument is paired up character by character.
+func ListZip(vm *TenoriteVM, args []Receiver) Receiver {
+    _, isString := args[1].(String)
+    if !IsCollection(args[1]) && !isString {
+        vm.Error = kindError("type", "Argument must be a collection.")
+        return nil
+    }
+    left, right := itemsOf(args[0]), itemsOf(args[1])
+    n := len(left)
+    if len(right) < n { n = len(right) }
+    pairs := make([]Receiver, n)
+    for i := range pairs {
+        pairs[i] = Pair { left[i], right[i] }
+    }
+    return List { pairs }
+}
@@ -2,6 +2,8 @@ package interpreter
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 type Message struct {
@@ -17,83 +19,162 @@ func CallUnsafe(vm *TenoriteVM, recv Receiver, name string, args []Receiver) Rec
 	return r
 }
 
-func allZero(ranks []int) bool {
+// Ranks are kept per argument, shifted so that the zero value means none and
+// the message gets the whole argument. A rank k+1 sends it to every rank-k
+// cell of the argument and a negative rank -k to the cells k axes below the
+// argument's own rank, so the bare `@`, stored as -1, sends it to each item.
+func cellRank(rank int, argRank int) int {
+	switch {
+	case rank == 0:
+		return argRank
+	case rank > 0:
+		if rank-1 < argRank { return rank-1 }
+		return argRank
+	}
+	if argRank+rank < 0 { return 0 }
+	return argRank+rank
+}
+
+func hasRanks(ranks []int) bool {
 	for _, i := range ranks {
-		if i > 0 { return false }
+		if i != 0 { return true }
 	}
-	return true
+	return false
 }
 
+// An unranked message goes to the items of its collection arguments when the
+// receiver is a collection without a method for it, or a number sending one
+// of the Number primitives. Collections without axes have no items and are
+// passed whole.
 func Call(vm *TenoriteVM, msg Message, args []Receiver) (Receiver, error) {
-	// _, isList := args[0].(List)
-	// if isList && (msg.Ranks == nil || msg.Ranks[0] == 0) {
-	if IsCollection(args[0]) && (msg.Ranks == nil || allZero(msg.Ranks)) {
-		method := args[0].GetMethod(msg.Symbol)
-		if method == nil {
-			msg.Ranks[0] = 1
-
-			// return Run(vm, method, args)
+	if !hasRanks(msg.Ranks) && promotes(msg.Symbol, args) {
+		ranks := make([]int, len(args))
+		for i, arg := range args {
+			if IsCollection(arg) && len(shapeOf(arg)) > 0 { ranks[i] = -1 }
 		}
-		// fmt.Printf("Ranks %s %v\n", msg.Symbol, msg.Ranks)
-		return CallRec_(vm, 0, msg, args)
+		msg = Message { msg.Symbol, ranks }
 	}
 
-	return CallRec_(vm, 0, msg, args)
+	return CallRec_(vm, msg, args)
 }
 
-func CallRec_(vm *TenoriteVM, depth int, msg Message, args []Receiver) (Receiver, error) {
-	arity := len(msg.Ranks)
-
-	var toZip []int
-	for i := 0; i < arity; i++ {
-		if msg.Ranks[i]-depth == 1 && IsCollection(args[i]) {
-			toZip = append(toZip, i)
+func promotes(sym Symbol, args []Receiver) bool {
+	if IsCollection(args[0]) {
+		return args[0].GetMethod(sym) == nil
+	}
+	if !isNumeric(args[0]) { return false }
+	for _, arg := range args[1:] {
+		if IsCollection(arg) {
+			_, isPrimitive := NumberNs.Get(sym).(Primitive)
+			return isPrimitive
 		}
 	}
+	return false
+}
 
-	if toZip == nil {
-		method := args[0].GetMethod(msg.Symbol)
-		if method == nil {
-			return nil, fmt.Errorf("Invalid method #%s for %v. Ranks %v", vm.SymbolStore[msg.Symbol], args[0], msg.Ranks)
+// Splits every ranked argument into a frame of cells. The frames have to
+// agree on their leading axes, and an argument with a shorter frame, a
+// scalar included, is extended to every cell of the longer ones.
+func CallRec_(vm *TenoriteVM, msg Message, args []Receiver) (Receiver, error) {
+	if !hasRanks(msg.Ranks) {
+		return send(vm, msg, args)
+	}
+	vm.leaveFrame()
+
+	frames := make([]int, len(args))
+	shapes := make([][]int, len(args))
+	longest := 0
+	for i, arg := range args {
+		if msg.Ranks[i] == 0 { continue }
+		shapes[i] = shapeOf(arg)
+		frames[i] = len(shapes[i]) - cellRank(msg.Ranks[i], len(shapes[i]))
+		if frames[i] > frames[longest] { longest = i }
+	}
+	frame := shapes[longest][:frames[longest]]
+	for i := range args {
+		if !sameShape(shapes[i][:frames[i]], frame[:frames[i]]) {
+			return nil, kindError("size", "Length error in #%s: shapes %s and %s do not agree",
+				vm.SymbolStore[msg.Symbol], formatShape(shapes[longest]), formatShape(shapes[i]))
 		}
-		return Run(vm, method, args)
 	}
-	
 
-	size := Size(args[toZip[0]])
-	for _, i := range toZip {
-		if Size(args[i]) != size {
-			return nil, fmt.Errorf("Differing sizes")
-		}
+	return callFrames(vm, msg, args, frames)
+}
+
+func formatShape(shape []int) string {
+	axes := make([]string, len(shape))
+	for i, axis := range shape {
+		axes[i] = strconv.Itoa(axis)
 	}
+	return "[" + strings.Join(axes, ", ") + "]"
+}
 
-	// fmt.Printf("ziping %v Ranks %s %v-%d\n", toZip, msg.Symbol, msg.Ranks, depth)
+func callFrames(vm *TenoriteVM, msg Message, args []Receiver, frames []int) (Receiver, error) {
+	lead := -1
+	for i, frame := range frames {
+		if frame > 0 { lead = i; break }
+	}
+	if lead == -1 {
+		return Call(vm, Message { msg.Symbol, nil }, args)
+	}
 
+	if result, ok := vectorCall(msg, args, frames); ok {
+		return result, nil
+	}
+
+	cellFrames := make([]int, len(frames))
+	for i, frame := range frames {
+		if frame > 0 { cellFrames[i] = frame-1 }
+	}
+
+	size := Size(args[lead])
 	var result = make([]Receiver, size)
-	for i := 0; i < size; i++ {
-		var newArgs = make([]Receiver, arity)
-		for j := 0; j < arity; j++ {
-			if msg.Ranks[j]-depth == 1 {
-				newArgs[j] = GetAt(args[j], i)
+	for j := 0; j < size; j++ {
+		var cellArgs = make([]Receiver, len(args))
+		for i, arg := range args {
+			if frames[i] > 0 {
+				cellArgs[i] = GetAt(arg, j)
 			} else {
-				newArgs[j] = args[j]
+				cellArgs[i] = arg
 			}
 		}
 
-		r, err := CallRec_(vm, depth+1, msg, newArgs)
+		r, err := callFrames(vm, msg, cellArgs, cellFrames)
 		if err != nil { return nil, err }
-		result[i] = r
+		result[j] = r
 	}
 
-	if msg.Ranks[0] == 1 {
-		tbl, isTable := args[0].(Table)
-		if isTable {
-			collected := Table { tbl.Keys, result }
-			return collected, nil
-		}
+	if tbl, isTable := args[lead].(*Table); isTable && frames[lead] == 1 {
+		return tbl.withValues(result), nil
+	}
+	return assemble(args[lead], result), nil
+}
 
+// Applies f to x and y with the given ranks, a Symbol is sent to x and a
+// Function is called with both.
+func combine(vm *TenoriteVM, f Receiver, ranks []int, x, y Receiver) (Receiver, error) {
+	if sym, isSymbol := f.(Symbol); isSymbol {
+		return Call(vm, Message { sym, ranks }, []Receiver{ x, y })
 	}
-	collected := List { result }
+	return Call(vm, Message { SYM_VALUE2, append([]int{ 0 }, ranks...) }, []Receiver{ f, x, y })
+}
 
-	return collected, nil
-}
\ No newline at end of file
+func send(vm *TenoriteVM, msg Message, args []Receiver) (Receiver, error) {
+	method := args[0].GetMethod(msg.Symbol)
+	if !callsClosure(msg.Symbol, method, args[0]) {
+		vm.leaveFrame()
+	}
+	if method == nil {
+		return nil, kindError("method", "Invalid method #%s for %s. Ranks %v", vm.SymbolStore[msg.Symbol], toDebugString(vm, args[0]), msg.Ranks)
+	}
+	_, isPrimitive := method.(Primitive)
+	if isPrimitive && ArrayNs.Get(msg.Symbol) == nil {
+		args = unpackArgs(args)
+	}
+	// A primitive's error is told apart by the message that failed.
+	result, err := Run(vm, method, args)
+	if err != nil && isPrimitive {
+		return nil, fmt.Errorf("%w (in #%s)", err, vm.SymbolStore[msg.Symbol])
+	}
+	return result, err
+}
@@ -0,0 +1,371 @@
+package interpreter
+
+import (
+    "math"
+    "math/big"
+    "strconv"
+    "strings"
+)
+
+// The numeric tower, from narrowest to widest: Number (exact machine
+// integers), BigInt, Rational and Float. Operations widen both sides to the
+// wider kind and narrow exact results back down as far as they go, so Floats
+// only come out of Float inputs or an explicit asFloat.
+
+type numKind int
+
+const (
+    kindInt numKind = iota
+    kindBig
+    kindRat
+    kindFloat
+)
+
+func numericKind(r Receiver) (numKind, bool) {
+    switch r.(type) {
+    case Number: return kindInt, true
+    case BigInt: return kindBig, true
+    case Rational: return kindRat, true
+    case Float: return kindFloat, true
+    }
+    return 0, false
+}
+
+func isNumeric(r Receiver) bool {
+    _, ok := numericKind(r)
+    return ok
+}
+
+func validateNumeric(vm *TenoriteVM, value Receiver, name string) bool {
+    ok := isNumeric(value)
+    vm.Error = kindError("type", "%s must be a number.", name)
+    return ok
+}
+
+func toBigInt(r Receiver) *big.Int {
+    switch n := r.(type) {
+    case Number: return big.NewInt(int64(n))
+    case BigInt: return n.Int
+    }
+    return nil
+}
+
+func toRat(r Receiver) *big.Rat {
+    switch n := r.(type) {
+    case Number: return new(big.Rat).SetInt64(int64(n))
+    case BigInt: return new(big.Rat).SetInt(n.Int)
+    case Rational: return n.Rat
+    }
+    return nil
+}
+
+func toFloat(r Receiver) float64 {
+    switch n := r.(type) {
+    case Number: return float64(n)
+    case BigInt:
+        f, _ := new(big.Float).SetInt(n.Int).Float64()
+        return f
+    case Rational:
+        f, _ := n.Rat.Float64()
+        return f
+    case Float: return float64(n)
+    }
+    return math.NaN()
+}
+
+func normalizeBig(n *big.Int) Receiver {
+    if n.IsInt64() { return Number(n.Int64()) }
+    return BigInt { n }
+}
+
+func normalizeRat(q *big.Rat) Receiver {
+    if q.IsInt() { return normalizeBig(new(big.Int).Set(q.Num())) }
+    return Rational { q }
+}
+
+// Parses a numeric literal, integers are exact and anything with a fraction
+// is a Float.
+func ParseNumber(literal string) (Receiver, bool) {
+    if strings.ContainsAny(literal, ".eE") {
+        f, err := strconv.ParseFloat(literal, 64)
+        if err != nil { return nil, false }
+        return Float(f), true
+    }
+    n, ok := new(big.Int).SetString(literal, 10)
+    if !ok { return nil, false }
+    return normalizeBig(n), true
+}
+
+var errDivisionByZero = kindError("arithmetic", "Division by zero")
+
+func intArith(op byte, a, b int64) (Receiver, bool) {
+    switch op {
+    case '+':
+        s := a + b
+        if (a > 0 && b > 0 && s < 0) || (a < 0 && b < 0 && s >= 0) { return nil, false }
+        return Number(s), true
+    case '-':
+        s := a - b
+        if (a >= 0 && b < 0 && s < 0) || (a < 0 && b > 0 && s >= 0) { return nil, false }
+        return Number(s), true
+    case '*':
+        if a == 0 || b == 0 { return Number(0), true }
+        s := a * b
+        if s/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+            return nil, false
+        }
+        return Number(s), true
+    case '/':
+        if a%b != 0 || (a == math.MinInt64 && b == -1) { return nil, false }
+        return Number(a / b), true
+    case '%':
+        return Number(a % b), true
+    }
+    return nil, false
+}
+
+func numArith(op byte, a, b Receiver) (Receiver, error) {
+    ka, _ := numericKind(a)
+    kb, _ := numericKind(b)
+    kind := ka
+    if kb > kind { kind = kb }
+
+    if kind != kindFloat && (op == '/' || op == '%') && toRat(b).Sign() == 0 {
+        return nil, errDivisionByZero
+    }
+
+    switch kind {
+    case kindInt:
+        if r, ok := intArith(op, int64(a.(Number)), int64(b.(Number))); ok {
+            return r, nil
+        }
+        fallthrough
+    case kindBig:
+        x, y := toBigInt(a), toBigInt(b)
+        switch op {
+        case '+': return normalizeBig(new(big.Int).Add(x, y)), nil
+        case '-': return normalizeBig(new(big.Int).Sub(x, y)), nil
+        case '*': return normalizeBig(new(big.Int).Mul(x, y)), nil
+        case '%': return normalizeBig(new(big.Int).Rem(x, y)), nil
+        case '/':
+            q, r := new(big.Int).QuoRem(x, y, new(big.Int))
+            if r.Sign() == 0 { return normalizeBig(q), nil }
+            return normalizeRat(new(big.Rat).SetFrac(x, y)), nil
+        }
+    case kindRat:
+        x, y := toRat(a), toRat(b)
+        switch op {
+        case '+': return normalizeRat(new(big.Rat).Add(x, y)), nil
+        case '-': return normalizeRat(new(big.Rat).Sub(x, y)), nil
+        case '*': return normalizeRat(new(big.Rat).Mul(x, y)), nil
+        case '/': return normalizeRat(new(big.Rat).Quo(x, y)), nil
+        case '%':
+            q := new(big.Rat).Quo(x, y)
+            whole := new(big.Int).Quo(q.Num(), q.Denom())
+            return normalizeRat(new(big.Rat).Sub(x, new(big.Rat).Mul(y, new(big.Rat).SetInt(whole)))), nil
+        }
+    case kindFloat:
+        x, y := toFloat(a), toFloat(b)
+        switch op {
+        case '+': return Float(x + y), nil
+        case '-': return Float(x - y), nil
+        case '*': return Float(x * y), nil
+        case '/': return Float(x / y), nil
+        case '%': return Float(math.Mod(x, y)), nil
+        }
+    }
+    return nil, kindError("arithmetic", "Unknown operation %c", op)
+}
+
+// Exact bases raised to integer exponents stay exact, a negative exponent
+// gives the reciprocal. Everything else is computed with floats.
+func numPower(a, b Receiver) (Receiver, error) {
+    ka, _ := numericKind(a)
+    kb, _ := numericKind(b)
+    if ka == kindFloat || kb == kindRat || kb == kindFloat {
+        return Float(math.Pow(toFloat(a), toFloat(b))), nil
+    }
+    e := toBigInt(b)
+    if !e.IsInt64() {
+        return Float(math.Pow(toFloat(a), toFloat(b))), nil
+    }
+    base := toRat(a)
+    exp := e.Int64()
+    if exp < 0 {
+        if base.Sign() == 0 { return nil, errDivisionByZero }
+        base = new(big.Rat).Inv(base)
+        exp = -exp
+    }
+    num := new(big.Int).Exp(base.Num(), big.NewInt(exp), nil)
+    den := new(big.Int).Exp(base.Denom(), big.NewInt(exp), nil)
+    return normalizeRat(new(big.Rat).SetFrac(num, den)), nil
+}
+
+// Answers -1, 0 or 1, ordered is false when either side is NaN.
+func numCompare(a, b Receiver) (result int, ordered bool) {
+    ka, _ := numericKind(a)
+    kb, _ := numericKind(b)
+    switch {
+    case ka == kindInt && kb == kindInt:
+        x, y := a.(Number), b.(Number)
+        if x < y { return -1, true }
+        if x > y { return 1, true }
+        return 0, true
+    case ka == kindFloat || kb == kindFloat:
+        x, y := toFloat(a), toFloat(b)
+        if math.IsNaN(x) || math.IsNaN(y) { return 0, false }
+        if x < y { return -1, true }
+        if x > y { return 1, true }
+        return 0, true
+    }
+    return toRat(a).Cmp(toRat(b)), true
+}
+
+func numEqual(a, b Receiver) bool {
+    if !isNumeric(b) { return false }
+    c, ordered := numCompare(a, b)
+    return ordered && c == 0
+}
+
+func formatNumber(r Receiver) string {
+    switch n := r.(type) {
+    case Number: return strconv.FormatInt(int64(n), 10)
+    case BigInt: return n.Int.String()
+    case Rational: return n.Rat.String()
+    case Float:
+        s := strconv.FormatFloat(float64(n), 'g', -1, 64)
+        if !strings.ContainsAny(s, ".eIN") { s += ".0" }
+        return s
+    }
+    return ""
+}
+
+func numPrimitive(op byte) Primitive {
+    return Primitive { func(vm *TenoriteVM, args []Receiver) Receiver {
+        if !validateNumeric(vm, args[1], "Right side") { return nil }
+        result, err := numArith(op, args[0], args[1])
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        return result
+    } }
+}
+
+func numComparison(test func(int) bool) Primitive {
+    return Primitive { func(vm *TenoriteVM, args []Receiver) Receiver {
+        if !validateNumeric(vm, args[1], "Right side") { return nil }
+        c, ordered := numCompare(args[0], args[1])
+        return toBool(ordered && test(c))
+    } }
+}
+
+func NumPower(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumeric(vm, args[1], "Right side") { return nil }
+    result, err := numPower(args[0], args[1])
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
+func NumMin(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumeric(vm, args[1], "Right side") { return nil }
+    if c, _ := numCompare(args[1], args[0]); c < 0 { return args[1] }
+    return args[0]
+}
+
+func NumMax(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumeric(vm, args[1], "Right side") { return nil }
+    if c, _ := numCompare(args[1], args[0]); c > 0 { return args[1] }
+    return args[0]
+}
+
+func NumEqual(vm *TenoriteVM, args []Receiver) Receiver {
+    return toBool(numEqual(args[0], args[1]))
+}
+
+func NumNotEqual(vm *TenoriteVM, args []Receiver) Receiver {
+    return toBool(!numEqual(args[0], args[1]))
+}
+
+func NumString(vm *TenoriteVM, args []Receiver) Receiver {
+    return String(formatNumber(args[0]))
+}
+
+func NumAsFloat(vm *TenoriteVM, args []Receiver) Receiver {
+    return Float(toFloat(args[0]))
+}
+
+// Turns a whole Float into an exact integer, infinities and NaN stay Floats.
+func exactWhole(f float64) Receiver {
+    if math.IsInf(f, 0) || math.IsNaN(f) { return Float(f) }
+    b, _ := new(big.Float).SetFloat64(f).Int(nil)
+    return normalizeBig(b)
+}
+
+func NumFloor(vm *TenoriteVM, args []Receiver) Receiver {
+    switch n := args[0].(type) {
+    case Rational:
+        return normalizeBig(new(big.Int).Div(n.Rat.Num(), n.Rat.Denom()))
+    case Float:
+        return exactWhole(math.Floor(float64(n)))
+    }
+    return args[0]
+}
+
+// Rounds halves away from zero.
+func NumRound(vm *TenoriteVM, args []Receiver) Receiver {
+    switch n := args[0].(type) {
+    case Rational:
+        q := new(big.Rat).Abs(n.Rat)
+        q.Add(q, big.NewRat(1, 2))
+        whole := new(big.Int).Quo(q.Num(), q.Denom())
+        if n.Rat.Sign() < 0 { whole.Neg(whole) }
+        return normalizeBig(whole)
+    case Float:
+        return exactWhole(math.Round(float64(n)))
+    }
+    return args[0]
+}
+
+// Exact squares have exact roots, everything else answers a Float.
+func NumSqrt(vm *TenoriteVM, args []Receiver) Receiver {
+    if _, isFloat := args[0].(Float); !isFloat {
+        q := toRat(args[0])
+        if q.Sign() >= 0 {
+            num := new(big.Int).Sqrt(q.Num())
+            den := new(big.Int).Sqrt(q.Denom())
+            if new(big.Int).Mul(num, num).Cmp(q.Num()) == 0 && new(big.Int).Mul(den, den).Cmp(q.Denom()) == 0 {
+                return normalizeRat(new(big.Rat).SetFrac(num, den))
+            }
+        }
+    }
+    return Float(math.Sqrt(toFloat(args[0])))
+}
+
+func NumIsExact(vm *TenoriteVM, args []Receiver) Receiver {
+    _, isFloat := args[0].(Float)
+    return toBool(!isFloat)
+}
+
+func NumIsInteger(vm *TenoriteVM, args []Receiver) Receiver {
+    switch n := args[0].(type) {
+    case Number, BigInt: return TRUE
+    case Float:
+        f := float64(n)
+        return toBool(f == math.Trunc(f) && !math.IsInf(f, 0))
+    }
+    return FALSE
+}
+
+func NumNumerator(vm *TenoriteVM, args []Receiver) Receiver {
+    if _, isFloat := args[0].(Float); isFloat { return args[0] }
+    return normalizeBig(new(big.Int).Set(toRat(args[0]).Num()))
+}
+
+func NumDenominator(vm *TenoriteVM, args []Receiver) Receiver {
+    if _, isFloat := args[0].(Float); isFloat { return Float(1) }
+    return normalizeBig(new(big.Int).Set(toRat(args[0]).Denom()))
+}
@@ -21,17 +21,32 @@ const (
     OP_CALL
     OP_CALL_R
     OP_CALL_0R1
+    OP_TAIL_CALL
 
     OP_JUMP_FALSE
+    OP_JUMP
     OP_LOOP
+    OP_ITERATE
 
     OP_POP
     OP_PRINT
     OP_RETURN
+    OP_NONLOCAL_RETURN
+    OP_YIELD
     OP_CLOSE_UPVALUE
 
     OP_MAKE_LIST
     OP_MAKE_TABLE
+    OP_MAKE_RECORD
+
+    OP_UNPACK_LIST
+    OP_UNPACK_PAIR
+    OP_UNPACK_KEY
+
+    OP_MATCH_LIST
+    OP_MATCH_PAIR
+    OP_MATCH_REGEX
+    OP_NO_MATCH
     
     OP_MAKE_METHOD
     OP_MAKE_STATIC
@@ -63,14 +78,27 @@ var OPCODE_NAMES = [...]string{
     OP_CALL: "CALL",
     OP_CALL_R: "CALL_R",
     OP_CALL_0R1: "CALL_0R1",
+    OP_TAIL_CALL: "TAIL_CALL",
     OP_JUMP_FALSE: "JUMP_FALSE",
+    OP_JUMP: "JUMP",
     OP_LOOP: "LOOP",
+    OP_ITERATE: "ITERATE",
     OP_POP: "POP",
     OP_PRINT: "PRINT",
     OP_RETURN: "RETURN",
+    OP_NONLOCAL_RETURN: "NONLOCAL_RETURN",
+    OP_YIELD: "YIELD",
     OP_CLOSE_UPVALUE: "CLOSE_UPVALUE",
     OP_MAKE_LIST: "MAKE_LIST",
     OP_MAKE_TABLE: "MAKE_TABLE",
+    OP_MAKE_RECORD: "MAKE_RECORD",
+    OP_UNPACK_LIST: "UNPACK_LIST",
+    OP_UNPACK_PAIR: "UNPACK_PAIR",
+    OP_UNPACK_KEY: "UNPACK_KEY",
+    OP_MATCH_LIST: "MATCH_LIST",
+    OP_MATCH_PAIR: "MATCH_PAIR",
+    OP_MATCH_REGEX: "MATCH_REGEX",
+    OP_NO_MATCH: "NO_MATCH",
     OP_MAKE_METHOD: "MAKE_METHOD",
     OP_MAKE_STATIC: "MAKE_STATIC",
     OP_MAKE_OBJ: "MAKE_OBJ",
@@ -3,6 +3,7 @@ package interpreter
 import (
     "hash/fnv"
     "fmt"
+    "math/big"
     // "strings"
 )
 
@@ -14,7 +15,9 @@ type Receiver interface {
 func Size(r Receiver) int {
     switch recv := r.(type) {
     case List: return len(recv.List)
-    case Table: return len(recv.Keys)
+    case *Table: return len(recv.Keys)
+    case Set: return len(recv.Members.Keys)
+    case Array: return recv.Len()
     }
     return 1
 }
@@ -22,7 +25,9 @@ func Size(r Receiver) int {
 func IsCollection(r Receiver) bool {
     switch r.(type) {
     case List: return true
-    case Table: return true
+    case *Table: return true
+    case Set: return true
+    case Array: return true
     }
     return false
 }
@@ -30,7 +35,9 @@ func IsCollection(r Receiver) bool {
 func GetAt(r Receiver, index int) Receiver {
     switch recv := r.(type) {
     case List: return recv.List[index]
-    case Table: return recv.Values[index]
+    case *Table: return recv.Values[index]
+    case Set: return recv.Members.Keys[index]
+    case Array: return recv.At(index)
     }
     return r
 }
@@ -46,7 +53,12 @@ var FALSE = False{}
 var NONE = None{}
 
 type String string
-type Number float64
+
+// Numbers are exact integers, see numeric.go for the rest of the tower.
+type Number int64
+type BigInt struct { Int *big.Int }
+type Rational struct { Rat *big.Rat }
+type Float float64
 
 
 func (_ True) String() string { return "True" }
@@ -62,6 +74,13 @@ type List struct {
 type Table struct {
     Keys     []Receiver
     Values   []Receiver
+    Index    map[uint64][]int
+    Hashes   []uint64
+}
+
+// A Set keeps its members as the keys of a table, in insertion order.
+type Set struct {
+    Members  *Table
 }
 
 type Pair struct {
@@ -92,7 +111,10 @@ func (sym Symbol) String() string {
 
 var (
     SYM_ITERATE = makeSymbol("iterate:")
+    SYM_NEXT = makeSymbol("next:")
     SYM_VALUE = makeSymbol("value:")
+    SYM_VALUE2 = makeSymbol("value:value:")
+    SYM_IDENTICAL = makeSymbol("===")
 )
 
 // == Namespace ==
@@ -123,8 +145,9 @@ func (ns *Namespace) String() string {
 // == Tuple ==
 
 type Object struct {
-    Roles  []*Namespace
-    Table  map[Symbol]Receiver
+    Roles   []*Namespace
+    Table   map[Symbol]Receiver
+    Fields  []Symbol
 }
 
 // == Foreign ==
@@ -142,6 +165,7 @@ var NumberNs = NewNamespace("Number")
 var FunctionNs = NewNamespace("Function")
 var ListNs = NewNamespace("List")
 var TableNs = NewNamespace("Table")
+var SetNs = NewNamespace("Set")
 var RangeNs = NewNamespace("Range")
 var NamespaceNs = NewNamespace("Namespace")
 var SymbolNs = NewNamespace("Symbol")
@@ -150,6 +174,9 @@ var PairNs = NewNamespace("Pair")
 var RegexNs = NewNamespace("Regex")
 var RegexResultsNs = NewNamespace("RegexResults")
 
+var ErrorNs = NewNamespace("Error")
+var RecordNs = NewNamespace("Record")
+
 var EqNs = NewNamespace("Eq")
 var OrdNs = NewNamespace("Ord")
 
@@ -172,7 +199,20 @@ func (_ Number) GetMethod(sym Symbol) (meth Receiver) {
     meth = NumberNs.Get(sym); if meth != nil { return }
     return ObjectNs.Get(sym)
 }
-func (_ Symbol) GetMethod(sym Symbol) Receiver {
+func (_ BigInt) GetMethod(sym Symbol) (meth Receiver) {
+    meth = NumberNs.Get(sym); if meth != nil { return }
+    return ObjectNs.Get(sym)
+}
+func (_ Rational) GetMethod(sym Symbol) (meth Receiver) {
+    meth = NumberNs.Get(sym); if meth != nil { return }
+    return ObjectNs.Get(sym)
+}
+func (_ Float) GetMethod(sym Symbol) (meth Receiver) {
+    meth = NumberNs.Get(sym); if meth != nil { return }
+    return ObjectNs.Get(sym)
+}
+func (_ Symbol) GetMethod(sym Symbol) (meth Receiver) {
+    meth = SymbolNs.Get(sym); if meth != nil { return }
     return ObjectNs.Get(sym)
 }
 func (_ Range) GetMethod(sym Symbol) (meth Receiver) {
@@ -187,10 +227,14 @@ func (_ List) GetMethod(sym Symbol) (meth Receiver) {
     meth = ListNs.Get(sym); if meth != nil { return }
     return ObjectNs.Get(sym)
 }
-func (_ Table) GetMethod(sym Symbol) (meth Receiver) {
+func (_ *Table) GetMethod(sym Symbol) (meth Receiver) {
     meth = TableNs.Get(sym); if meth != nil { return }
     return ObjectNs.Get(sym)
 }
+func (_ Set) GetMethod(sym Symbol) (meth Receiver) {
+    meth = SetNs.Get(sym); if meth != nil { return }
+    return ObjectNs.Get(sym)
+}
 func (self *Namespace) GetMethod(sym Symbol) Receiver {
     if self.Static != nil {
         meth := self.Static.Get(sym)
@@ -201,6 +245,8 @@ func (self *Namespace) GetMethod(sym Symbol) Receiver {
 
     return ObjectNs.Get(sym)
 }
+// The fields of a record answer the selectors its methods leave free, a
+// field named like a method is read with at:.
 func (self Object) GetMethod(sym Symbol) Receiver {
     for _, role := range self.Roles {
         meth := role.Get(sym)
@@ -208,7 +254,11 @@ func (self Object) GetMethod(sym Symbol) Receiver {
             return meth
         }
     }
-    return ObjectNs.Get(sym)
+    if meth := ObjectNs.Get(sym); meth != nil { return meth }
+    if self.Fields != nil {
+        if _, ok := self.Table[sym]; ok { return recordField(sym) }
+    }
+    return nil
 }
 func (_ *Closure) GetMethod(sym Symbol) (meth Receiver) {
     meth = FunctionNs.Get(sym); if meth != nil { return }
@@ -237,11 +287,15 @@ func (_ True) Type(r Receiver) bool { return r == BoolNs || r == ObjectNs }
 func (_ False) Type(r Receiver) bool { return r == BoolNs || r == ObjectNs }
 func (_ String) Type(r Receiver) bool { return r == StringNs || r == ObjectNs }
 func (_ Number) Type(r Receiver) bool { return r == NumberNs || r == ObjectNs }
+func (_ BigInt) Type(r Receiver) bool { return r == NumberNs || r == ObjectNs }
+func (_ Rational) Type(r Receiver) bool { return r == NumberNs || r == ObjectNs }
+func (_ Float) Type(r Receiver) bool { return r == NumberNs || r == ObjectNs }
 func (_ Symbol) Type(r Receiver) bool { return r == SymbolNs || r == ObjectNs }
 func (_ Range) Type(r Receiver) bool { return r == RangeNs || r == ObjectNs }
 func (_ Pair) Type(r Receiver) bool { return r == PairNs || r == ObjectNs }
 func (_ List) Type(r Receiver) bool { return r == ListNs || r == ObjectNs }
-func (_ Table) Type(r Receiver) bool { return r == TableNs || r == ObjectNs }
+func (_ *Table) Type(r Receiver) bool { return r == TableNs || r == ObjectNs }
+func (_ Set) Type(r Receiver) bool { return r == SetNs || r == ObjectNs }
 func (_ *Namespace) Type(r Receiver) bool { return r == NamespaceNs || r == ObjectNs }
 func (_ *Closure) Type(r Receiver) bool { return r == FunctionNs || r == ObjectNs }
 func (_ Primitive) Type(r Receiver) bool { return r == FunctionNs || r == ObjectNs }
@@ -51,7 +51,7 @@ func StringFindRegex(vm *TenoriteVM, args []Receiver) Receiver {
     objmap[vm.Symbol("spans")] = List { spans }
     objmap[vm.Symbol("matched")] = toBool(indexes != nil)
     objmap[vm.Symbol("subject")] = self
-    return Object { []*Namespace{RegexResultsNs}, objmap }
+    return Object { Roles: []*Namespace{RegexResultsNs}, Table: objmap }
 }
 
 func StringFindRegexStart(vm *TenoriteVM, args []Receiver) Receiver {
@@ -89,5 +89,5 @@ func StringFindRegexStart(vm *TenoriteVM, args []Receiver) Receiver {
     objmap[vm.Symbol("spans")] = List { spans }
     objmap[vm.Symbol("matched")] = toBool(indexes != nil)
     objmap[vm.Symbol("subject")] = self_
-    return Object { []*Namespace{RegexResultsNs}, objmap }
+    return Object { Roles: []*Namespace{RegexResultsNs}, Table: objmap }
 }
@@ -0,0 +1,410 @@
+package interpreter
+
+// A Seq is a lazy sequence. It only describes how to produce its items: every
+// traversal opens a fresh step function and pulls the items one at a time, so
+// a pipeline over an infinite source computes just what is consumed. Ranges,
+// collections, Strings, generator blocks and coroutines all turn into a Seq
+// with #seq.
+
+type Seq struct {
+    Open func() seqStep
+}
+
+// Answers the next item, ok is false once the sequence is exhausted.
+type seqStep func(vm *TenoriteVM) (item Receiver, ok bool, err error)
+
+var SeqNs = NewNamespace("Seq")
+
+func (_ *Seq) GetMethod(sym Symbol) (meth Receiver) {
+    meth = SeqNs.Get(sym); if meth != nil { return }
+    return ObjectNs.Get(sym)
+}
+func (_ *Seq) Type(r Receiver) bool { return r == SeqNs || r == ObjectNs }
+
+// The cursor of a for loop over a Seq or a Coroutine, it holds the item
+// last pulled.
+type seqCursor struct {
+    step seqStep
+    item Receiver
+}
+
+func (_ *seqCursor) GetMethod(sym Symbol) Receiver { return ObjectNs.Get(sym) }
+func (_ *seqCursor) Type(r Receiver) bool { return r == ObjectNs }
+
+// Answers start as the first item of a count by step, a Float step makes
+// every item a Float like the sums after it.
+func firstItem(start, step Receiver) Receiver {
+    if kind, _ := numericKind(step); kind == kindFloat {
+        return Float(toFloat(start))
+    }
+    return start
+}
+
+// Counts up from from by step, without end.
+func countFrom(from, step Receiver) *Seq {
+    return &Seq { func() seqStep {
+        next := firstItem(from, step)
+        return func(vm *TenoriteVM) (Receiver, bool, error) {
+            item := next
+            var err error
+            next, err = numArith('+', next, step)
+            return item, err == nil, err
+        }
+    } }
+}
+
+// Walks from one end of the range to the other, step is positive and is
+// taken downwards when the range runs backwards.
+func rangeSeq(r Range, step Receiver) *Seq {
+    if r.From > r.To {
+        step, _ = numArith('-', Number(0), step)
+    }
+    return &Seq { func() seqStep {
+        next := firstItem(r.From, step)
+        return func(vm *TenoriteVM) (Receiver, bool, error) {
+            c, _ := numCompare(next, r.To)
+            if (r.From <= r.To && c > 0) || (r.From > r.To && c < 0) { return nil, false, nil }
+            item := next
+            var err error
+            next, err = numArith('+', next, step)
+            return item, err == nil, err
+        }
+    } }
+}
+
+func collectionSeq(r Receiver) *Seq {
+    return &Seq { func() seqStep {
+        i := 0
+        return func(vm *TenoriteVM) (Receiver, bool, error) {
+            if i >= Size(r) { return nil, false, nil }
+            i++
+            return GetAt(r, i-1), true, nil
+        }
+    } }
+}
+
+// Calls the block for every item until it answers None.
+func generatorSeq(f Receiver) *Seq {
+    return &Seq { func() seqStep {
+        done := false
+        return func(vm *TenoriteVM) (Receiver, bool, error) {
+            if done { return nil, false, nil }
+            item, err := Run(vm, f, []Receiver{ f })
+            if err != nil { return nil, false, err }
+            if _, isNone := item.(None); isNone {
+                done = true
+                return nil, false, nil
+            }
+            return item, true, nil
+        }
+    } }
+}
+
+func toSeq(r Receiver) (*Seq, bool) {
+    switch r := r.(type) {
+    case *Seq: return r, true
+    case Range: return rangeSeq(r, Number(1)), true
+    case *Closure, Primitive: return generatorSeq(r), true
+    case *Coroutine: return coroutineSeq(r), true
+    case String: return collectionSeq(List { itemsOf(r) }), true
+    }
+    if IsCollection(r) { return collectionSeq(r), true }
+    return nil, false
+}
+
+func validateSeq(vm *TenoriteVM, value Receiver, name string) (*Seq, bool) {
+    seq, ok := toSeq(value)
+    vm.Error = kindError("type", "%s must be a sequence.", name)
+    return seq, ok
+}
+
+func seqItems(vm *TenoriteVM, seq *Seq) ([]Receiver, error) {
+    items := []Receiver{}
+    step := seq.Open()
+    for {
+        item, ok, err := step(vm)
+        if err != nil { return nil, err }
+        if !ok { return items, nil }
+        items = append(items, item)
+    }
+}
+
+func mapSeq(seq *Seq, f Receiver) *Seq {
+    return &Seq { func() seqStep {
+        step := seq.Open()
+        return func(vm *TenoriteVM) (Receiver, bool, error) {
+            item, ok, err := step(vm)
+            if !ok || err != nil { return nil, false, err }
+            item, err = Run(vm, f, []Receiver{ f, item })
+            return item, err == nil, err
+        }
+    } }
+}
+
+func filterSeq(seq *Seq, f Receiver) *Seq {
+    return &Seq { func() seqStep {
+        step := seq.Open()
+        return func(vm *TenoriteVM) (Receiver, bool, error) {
+            for {
+                item, ok, err := step(vm)
+                if !ok || err != nil { return nil, false, err }
+                test, err := Run(vm, f, []Receiver{ f, item })
+                if err != nil { return nil, false, err }
+                if !isFalsey(test) { return item, true, nil }
+            }
+        }
+    } }
+}
+
+// Keeps the items whose flag in mask is true, like List compress:.
+func compressSeq(seq, mask *Seq) *Seq {
+    return &Seq { func() seqStep {
+        step := zipSeq(seq, mask).Open()
+        return func(vm *TenoriteVM) (Receiver, bool, error) {
+            for {
+                item, ok, err := step(vm)
+                if !ok || err != nil { return nil, false, err }
+                pair := item.(Pair)
+                if !isFalsey(pair.Second) { return pair.First, true, nil }
+            }
+        }
+    } }
+}
+
+func takeSeq(seq *Seq, n int) *Seq {
+    return &Seq { func() seqStep {
+        step := seq.Open()
+        taken := 0
+        return func(vm *TenoriteVM) (Receiver, bool, error) {
+            if taken >= n { return nil, false, nil }
+            taken++
+            return step(vm)
+        }
+    } }
+}
+
+func dropSeq(seq *Seq, n int) *Seq {
+    return &Seq { func() seqStep {
+        step := seq.Open()
+        dropped := 0
+        return func(vm *TenoriteVM) (Receiver, bool, error) {
+            for ; dropped < n; dropped++ {
+                _, ok, err := step(vm)
+                if !ok || err != nil { return nil, false, err }
+            }
+            return step(vm)
+        }
+    } }
+}
+
+func takeWhileSeq(seq *Seq, f Receiver) *Seq {
+    return &Seq { func() seqStep {
+        step := seq.Open()
+        done := false
+        return func(vm *TenoriteVM) (Receiver, bool, error) {
+            if done { return nil, false, nil }
+            item, ok, err := step(vm)
+            if !ok || err != nil { return nil, false, err }
+            test, err := Run(vm, f, []Receiver{ f, item })
+            if err != nil { return nil, false, err }
+            if isFalsey(test) {
+                done = true
+                return nil, false, nil
+            }
+            return item, true, nil
+        }
+    } }
+}
+
+// Pairs up the items of both sequences, ending with the shorter one.
+func zipSeq(left, right *Seq) *Seq {
+    return &Seq { func() seqStep {
+        nextLeft, nextRight := left.Open(), right.Open()
+        return func(vm *TenoriteVM) (Receiver, bool, error) {
+            x, ok, err := nextLeft(vm)
+            if !ok || err != nil { return nil, false, err }
+            y, ok, err := nextRight(vm)
+            if !ok || err != nil { return nil, false, err }
+            return Pair { x, y }, true, nil
+        }
+    } }
+}
+
+func validateCount(vm *TenoriteVM, value Receiver, name string) (int, bool) {
+    if !validateNumber(vm, value, name) { return 0, false }
+    n := int(value.(Number))
+    if n < 0 {
+        vm.Error = kindError("index", "%s must not be negative, got %d", name, n)
+        return 0, false
+    }
+    return n, true
+}
+
+func SeqFrom(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumeric(vm, args[1], "Start") { return nil }
+    return countFrom(args[1], Number(1))
+}
+
+func SeqFromBy(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumeric(vm, args[1], "Start") { return nil }
+    if !validateNumeric(vm, args[2], "Step") { return nil }
+    return countFrom(args[1], args[2])
+}
+
+func RangeBy(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumeric(vm, args[1], "Step") { return nil }
+    if c, _ := numCompare(args[1], Number(0)); c <= 0 {
+        vm.Error = kindError("index", "Step must be positive, got %s", formatNumber(args[1]))
+        return nil
+    }
+    return rangeSeq(args[0].(Range), args[1])
+}
+
+func SeqSeq(vm *TenoriteVM, args []Receiver) Receiver {
+    seq, _ := toSeq(args[0])
+    return seq
+}
+
+func SeqList(vm *TenoriteVM, args []Receiver) Receiver {
+    seq, _ := toSeq(args[0])
+    items, err := seqItems(vm, seq)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return packList(items)
+}
+
+func SeqMap(vm *TenoriteVM, args []Receiver) Receiver {
+    seq, _ := toSeq(args[0])
+    return mapSeq(seq, args[1])
+}
+
+// Filters with a block, or with a sequence of flags.
+func SeqFilter(vm *TenoriteVM, args []Receiver) Receiver {
+    seq, _ := toSeq(args[0])
+    switch args[1].(type) {
+    case *Closure, Primitive:
+        return filterSeq(seq, args[1])
+    }
+    mask, ok := validateSeq(vm, args[1], "Filter")
+    if !ok { return nil }
+    return compressSeq(seq, mask)
+}
+
+func SeqTake(vm *TenoriteVM, args []Receiver) Receiver {
+    seq, _ := toSeq(args[0])
+    n, ok := validateCount(vm, args[1], "Count")
+    if !ok { return nil }
+    return takeSeq(seq, n)
+}
+
+func SeqDrop(vm *TenoriteVM, args []Receiver) Receiver {
+    seq, _ := toSeq(args[0])
+    n, ok := validateCount(vm, args[1], "Count")
+    if !ok { return nil }
+    return dropSeq(seq, n)
+}
+
+func SeqTakeWhile(vm *TenoriteVM, args []Receiver) Receiver {
+    seq, _ := toSeq(args[0])
+    return takeWhileSeq(seq, args[1])
+}
+
+func SeqZip(vm *TenoriteVM, args []Receiver) Receiver {
+    seq, _ := toSeq(args[0])
+    other, ok := validateSeq(vm, args[1], "Argument")
+    if !ok { return nil }
+    return zipSeq(seq, other)
+}
+
+// Pulls items until one of them decides the answer, all and any stop early.
+func seqTest(vm *TenoriteVM, seq *Seq, stopOn bool) Receiver {
+    step := seq.Open()
+    for {
+        item, ok, err := step(vm)
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        if !ok { return toBool(!stopOn) }
+        if isFalsey(item) != stopOn { return toBool(stopOn) }
+    }
+}
+
+func SeqAll(vm *TenoriteVM, args []Receiver) Receiver {
+    seq, _ := toSeq(args[0])
+    return seqTest(vm, seq, false)
+}
+
+func SeqAny(vm *TenoriteVM, args []Receiver) Receiver {
+    seq, _ := toSeq(args[0])
+    return seqTest(vm, seq, true)
+}
+
+func SeqCount(vm *TenoriteVM, args []Receiver) Receiver {
+    seq, _ := toSeq(args[0])
+    step := seq.Open()
+    count := 0
+    for {
+        item, ok, err := step(vm)
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        if !ok { return Number(count) }
+        if !isFalsey(item) { count++ }
+    }
+}
+
+func SeqLen(vm *TenoriteVM, args []Receiver) Receiver {
+    step := args[0].(*Seq).Open()
+    n := 0
+    for {
+        _, ok, err := step(vm)
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        if !ok { return Number(n) }
+        n++
+    }
+}
+
+// Pulls the items up to index, so it answers on an infinite sequence too.
+func SeqAt_(vm *TenoriteVM, args []Receiver) Receiver {
+    if !validateNumber(vm, args[1], "Argument") { return nil }
+    index := int(args[1].(Number))
+    step := args[0].(*Seq).Open()
+    n := 0
+    for ; n <= index; n++ {
+        item, ok, err := step(vm)
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        if !ok { break }
+        if n == index { return item }
+    }
+    validateIndex(vm, index, n)
+    return nil
+}
+
+func SeqNext(vm *TenoriteVM, args []Receiver) Receiver {
+    cursor, ok := args[1].(*seqCursor)
+    if !ok {
+        cursor = &seqCursor { step: args[0].(*Seq).Open() }
+    }
+    item, ok, err := cursor.step(vm)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    if !ok { return NONE }
+    cursor.item = item
+    return cursor
+}
+
+func SeqIterate(vm *TenoriteVM, args []Receiver) Receiver {
+    return args[1].(*seqCursor).item
+}
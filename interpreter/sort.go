@@ -0,0 +1,228 @@
+package interpreter
+
+import (
+    "sort"
+    "strings"
+)
+
+// Sorting is stable and answers a new collection. Numbers, Strings and
+// Symbols compare natively, Lists compare item by item and any other value is
+// ordered by its #< method, which Ord derives from the other comparisons.
+// Tables sort their entries by value, Strings sort their characters.
+
+func compare(vm *TenoriteVM, a, b Receiver) (int, error) {
+    if isNumeric(a) && isNumeric(b) {
+        c, _ := numCompare(a, b)
+        return c, nil
+    }
+    if x, ok := a.(String); ok {
+        if y, ok := b.(String); ok { return strings.Compare(string(x), string(y)), nil }
+    }
+    if x, ok := a.(Symbol); ok {
+        if y, ok := b.(Symbol); ok { return strings.Compare(vm.SymbolStore[x], vm.SymbolStore[y]), nil }
+    }
+    if x, ok := asList(a); ok {
+        if y, ok := asList(b); ok {
+            for i := 0; i < len(x.List) && i < len(y.List); i++ {
+                c, err := compare(vm, x.List[i], y.List[i])
+                if err != nil || c != 0 { return c, err }
+            }
+            return compare(vm, Number(len(x.List)), Number(len(y.List)))
+        }
+    }
+
+    less, err := Call(vm, Message { SYM_LT, nil }, []Receiver{ a, b })
+    if err != nil { return 0, err }
+    if !isFalsey(less) { return -1, nil }
+    greater, err := Call(vm, Message { SYM_LT, nil }, []Receiver{ b, a })
+    if err != nil { return 0, err }
+    if !isFalsey(greater) { return 1, nil }
+    return 0, nil
+}
+
+// Answers the permutation that orders n items by cmp, equal items keep their
+// relative order in both directions.
+func gradeBy(n int, cmp func(i, j int) (int, error), descending bool) ([]int, error) {
+    perm := make([]int, n)
+    for i := range perm {
+        perm[i] = i
+    }
+    var err error
+    sort.SliceStable(perm, func(i, j int) bool {
+        if err != nil { return false }
+        var c int
+        c, err = cmp(perm[i], perm[j])
+        if descending { return c > 0 }
+        return c < 0
+    })
+    return perm, err
+}
+
+// Compares the items of source, packed arrays are compared in place.
+func naturalOrder(vm *TenoriteVM, source Receiver, items []Receiver) func(i, j int) (int, error) {
+    if array, ok := source.(Array); ok && len(array.shape()) == 1 && !array.isBoxed() {
+        if array.isFloat() {
+            return func(i, j int) (int, error) {
+                x, y := array.Floats[i], array.Floats[j]
+                if x < y { return -1, nil }
+                if x > y { return 1, nil }
+                return 0, nil
+            }
+        }
+        return func(i, j int) (int, error) {
+            x, y := array.Ints[i], array.Ints[j]
+            if x < y { return -1, nil }
+            if x > y { return 1, nil }
+            return 0, nil
+        }
+    }
+    return func(i, j int) (int, error) {
+        return compare(vm, items[i], items[j])
+    }
+}
+
+func sortItems(source Receiver) []Receiver {
+    switch source := source.(type) {
+    case String:
+        return itemsOf(source)
+    case *Table:
+        return source.Values
+    }
+    items := make([]Receiver, Size(source))
+    for i := range items {
+        items[i] = GetAt(source, i)
+    }
+    return items
+}
+
+// Answers source with its items in the order of perm.
+func permute(vm *TenoriteVM, source Receiver, items []Receiver, perm []int) (Receiver, error) {
+    sorted := make([]Receiver, len(perm))
+    for i, at := range perm {
+        sorted[i] = items[at]
+    }
+    if source, isTable := source.(*Table); isTable {
+        keys := make([]Receiver, len(perm))
+        values := make([]Receiver, len(perm))
+        for i, at := range perm {
+            keys[i] = source.Keys[at]
+            values[i] = source.Values[at]
+        }
+        return newTable(vm, keys, values)
+    }
+    return rebuild(source, sorted), nil
+}
+
+// Answers the key of every item under f, a Symbol or a block of one argument.
+func sortKeys(vm *TenoriteVM, f Receiver, items []Receiver) ([]Receiver, error) {
+    keys := make([]Receiver, len(items))
+    for i, item := range items {
+        var err error
+        if sym, isSymbol := f.(Symbol); isSymbol {
+            keys[i], err = Call(vm, Message { sym, nil }, []Receiver{ item })
+        } else {
+            keys[i], err = Run(vm, f, []Receiver{ f, item })
+        }
+        if err != nil { return nil, err }
+    }
+    return keys, nil
+}
+
+func sortPrimitive(descending bool) Primitive {
+    return Primitive { func(vm *TenoriteVM, args []Receiver) Receiver {
+        items := sortItems(args[0])
+        perm, err := gradeBy(len(items), naturalOrder(vm, args[0], items), descending)
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        result, err := permute(vm, args[0], items, perm)
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        return result
+    } }
+}
+
+func gradePrimitive(descending bool) Primitive {
+    return Primitive { func(vm *TenoriteVM, args []Receiver) Receiver {
+        items := sortItems(args[0])
+        perm, err := gradeBy(len(items), naturalOrder(vm, args[0], items), descending)
+        if err != nil {
+            vm.Error = err
+            return nil
+        }
+        indices := make([]Receiver, len(perm))
+        for i, at := range perm {
+            indices[i] = Number(at)
+            if tbl, isTable := args[0].(*Table); isTable { indices[i] = tbl.Keys[at] }
+        }
+        return packList(indices)
+    } }
+}
+
+func SeqSortBy(vm *TenoriteVM, args []Receiver) Receiver {
+    items := sortItems(args[0])
+    keys, err := sortKeys(vm, args[1], items)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    perm, err := gradeBy(len(items), naturalOrder(vm, nil, keys), false)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    result, err := permute(vm, args[0], items, perm)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
+// The comparator answers a number, negative when its first argument goes
+// first, or a Bool that is True when it does.
+func SeqSortWith(vm *TenoriteVM, args []Receiver) Receiver {
+    f := args[1]
+    items := sortItems(args[0])
+    perm, err := gradeBy(len(items), func(i, j int) (int, error) {
+        result, err := Run(vm, f, []Receiver{ f, items[i], items[j] })
+        if err != nil { return 0, err }
+        switch result.(type) {
+        case True: return -1, nil
+        case False: return 0, nil
+        }
+        if !isNumeric(result) {
+            return 0, kindError("type", "Comparator must answer a number or a Bool, got %s", toDebugString(vm, result))
+        }
+        c, _ := numCompare(result, Number(0))
+        return c, nil
+    }, false)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    result, err := permute(vm, args[0], items, perm)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
+
+func TableSortByKey(vm *TenoriteVM, args []Receiver) Receiver {
+    tbl := args[0].(*Table)
+    perm, err := gradeBy(len(tbl.Keys), naturalOrder(vm, nil, tbl.Keys), false)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    result, err := permute(vm, tbl, tbl.Values, perm)
+    if err != nil {
+        vm.Error = err
+        return nil
+    }
+    return result
+}
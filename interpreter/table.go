@@ -0,0 +1,276 @@
+package interpreter
+
+import (
+    "hash/fnv"
+    "math"
+    "math/big"
+    "reflect"
+)
+
+// Tables keep their entries in insertion order in Keys and Values, Index maps
+// the hash of a key to the positions of every key with that hash and Hashes
+// holds the hash of every key.
+
+var (
+    SYM_HASH = makeSymbol("hash")
+    SYM_EQUALS = makeSymbol("==")
+)
+
+const (
+    hashNone uint64 = 0x9e3779b97f4a7c15
+    hashTrue uint64 = 0xbf58476d1ce4e5b9
+    hashFalse uint64 = 0x94d049bb133111eb
+)
+
+func combineHash(h, x uint64) uint64 {
+    return (h ^ x) * 0x100000001b3
+}
+
+// Numbers are keys by value, so a number hashes like the exact value it
+// holds, 1 and 1.0 or 1/2 and 0.5 alike.
+func hashNumber(n Receiver) uint64 {
+    switch n := n.(type) {
+    case Number: return uint64(n)
+    case BigInt: return hashInt(n.Int)
+    case Rational: return hashRat(n.Rat)
+    case Float:
+        f := float64(n)
+        if f == 0 { return 0 }
+        if math.IsInf(f, 0) || math.IsNaN(f) { return math.Float64bits(f) }
+        return hashRat(new(big.Rat).SetFloat64(f))
+    }
+    return hashString(formatNumber(n))
+}
+
+func hashInt(i *big.Int) uint64 {
+    if i.IsInt64() { return uint64(i.Int64()) }
+    return hashString(i.String())
+}
+
+func hashRat(r *big.Rat) uint64 {
+    if r.IsInt() { return hashInt(r.Num()) }
+    return hashString(r.String())
+}
+
+func hashString(s string) uint64 {
+    h := fnv.New64a()
+    h.Write([]byte(s))
+    return h.Sum64()
+}
+
+// Objects answer their own hash when one of their roles defines #hash,
+// records hash by content and every other object hashes by identity.
+func userHashes(obj Object) bool {
+    for _, role := range obj.Roles {
+        if role.Get(SYM_HASH) != nil { return true }
+    }
+    return false
+}
+
+func identity(r Receiver) (uintptr, bool) {
+    value := reflect.ValueOf(r)
+    switch value.Kind() {
+    case reflect.Pointer, reflect.Map, reflect.Func:
+        return value.Pointer(), true
+    case reflect.Struct:
+        if obj, ok := r.(Object); ok {
+            return reflect.ValueOf(obj.Table).Pointer(), true
+        }
+    }
+    return 0, false
+}
+
+func hashKey(vm *TenoriteVM, key Receiver) (uint64, error) {
+    switch key := key.(type) {
+    case Number, BigInt, Rational, Float: return hashNumber(key), nil
+    case String: return hashString(string(key)), nil
+    case Symbol: return combineHash(hashNone, uint64(key)), nil
+    case None: return hashNone, nil
+    case True: return hashTrue, nil
+    case False: return hashFalse, nil
+    case Range:
+        return combineHash(hashNumber(key.From), hashNumber(key.To)), nil
+    case Pair:
+        first, err := hashKey(vm, key.First)
+        if err != nil { return 0, err }
+        second, err := hashKey(vm, key.Second)
+        if err != nil { return 0, err }
+        return combineHash(combineHash(hashTrue, first), second), nil
+    case Array:
+        return hashKey(vm, key.list())
+    case List:
+        h := hashFalse
+        for _, item := range key.List {
+            x, err := hashKey(vm, item)
+            if err != nil { return 0, err }
+            h = combineHash(h, x)
+        }
+        return h, nil
+    case Object:
+        if key.Fields != nil {
+            h := hashTrue
+            for _, field := range key.Fields {
+                x, err := hashKey(vm, key.Table[field])
+                if err != nil { return 0, err }
+                h = combineHash(combineHash(h, uint64(field)), x)
+            }
+            return h, nil
+        }
+        if userHashes(key) {
+            result, err := Call(vm, Message { SYM_HASH, make([]int, 1) }, []Receiver{ key })
+            if err != nil { return 0, err }
+            if !isNumeric(result) {
+                return 0, kindError("type", "#hash must answer a number, got %s", toDebugString(vm, result))
+            }
+            return hashNumber(result), nil
+        }
+    }
+    if id, ok := identity(key); ok {
+        return uint64(id), nil
+    }
+    return 0, nil
+}
+
+func keyEqual(vm *TenoriteVM, a, b Receiver) (bool, error) {
+    if array, ok := a.(Array); ok { a = array.list() }
+    if array, ok := b.(Array); ok { b = array.list() }
+    switch a := a.(type) {
+    case Number, BigInt, Rational, Float:
+        return numEqual(a, b), nil
+    case List:
+        b, ok := b.(List)
+        if !ok || len(a.List) != len(b.List) { return false, nil }
+        for i := range a.List {
+            same, err := keyEqual(vm, a.List[i], b.List[i])
+            if err != nil || !same { return false, err }
+        }
+        return true, nil
+    case Pair:
+        b, ok := b.(Pair)
+        if !ok { return false, nil }
+        same, err := keyEqual(vm, a.First, b.First)
+        if err != nil || !same { return false, err }
+        return keyEqual(vm, a.Second, b.Second)
+    case Object:
+        b, ok := b.(Object)
+        if !ok { return false, nil }
+        if a.Fields != nil {
+            if len(a.Fields) != len(b.Fields) { return false, nil }
+            for _, field := range a.Fields {
+                value, found := b.Table[field]
+                if !found { return false, nil }
+                same, err := keyEqual(vm, a.Table[field], value)
+                if err != nil || !same { return false, err }
+            }
+            return true, nil
+        }
+        if userHashes(a) {
+            result, err := Call(vm, Message { SYM_EQUALS, make([]int, 2) }, []Receiver{ a, b })
+            if err != nil { return false, err }
+            return !isFalsey(result), nil
+        }
+    }
+    if id, ok := identity(a); ok {
+        other, ok := identity(b)
+        return ok && id == other && reflect.TypeOf(a) == reflect.TypeOf(b), nil
+    }
+    if !reflect.TypeOf(a).Comparable() { return false, nil }
+    return a == b, nil
+}
+
+// Returns the position of key in table, or -1 when it is absent.
+func tableFind(vm *TenoriteVM, table *Table, key Receiver) (int, error) {
+    h, err := hashKey(vm, key)
+    if err != nil { return -1, err }
+    for _, i := range table.Index[h] {
+        same, err := keyEqual(vm, key, table.Keys[i])
+        if err != nil { return -1, err }
+        if same { return i, nil }
+    }
+    return -1, nil
+}
+
+func tableLookup(vm *TenoriteVM, table *Table, key Receiver) (Receiver, bool, error) {
+    i, err := tableFind(vm, table, key)
+    if err != nil || i == -1 { return nil, false, err }
+    return table.Values[i], true, nil
+}
+
+// Binds key to value in place. A key that is already present keeps its
+// position and only has its value replaced.
+func tableSet(vm *TenoriteVM, table *Table, key, value Receiver) error {
+    h, err := hashKey(vm, key)
+    if err != nil { return err }
+    for _, i := range table.Index[h] {
+        same, err := keyEqual(vm, key, table.Keys[i])
+        if err != nil { return err }
+        if same {
+            table.Values[i] = value
+            return nil
+        }
+    }
+    if table.Index == nil {
+        table.Index = make(map[uint64][]int)
+    }
+    table.Index[h] = append(table.Index[h], len(table.Keys))
+    table.Keys = append(table.Keys, key)
+    table.Values = append(table.Values, value)
+    table.Hashes = append(table.Hashes, h)
+    return nil
+}
+
+// Removes the entry at position at, shifting the later entries down. Only
+// the buckets of the removed key and of the keys after it change.
+func (table *Table) removeAt(at int) {
+    h := table.Hashes[at]
+    positions := table.Index[h]
+    for j, i := range positions {
+        if i != at { continue }
+        positions = append(positions[:j:j], positions[j+1:]...)
+        break
+    }
+    if len(positions) == 0 {
+        delete(table.Index, h)
+    } else {
+        table.Index[h] = positions
+    }
+
+    for i := at+1; i < len(table.Keys); i++ {
+        positions := table.Index[table.Hashes[i]]
+        for j := range positions {
+            if positions[j] == i { positions[j] = i-1 }
+        }
+    }
+    table.Keys = append(table.Keys[:at:at], table.Keys[at+1:]...)
+    table.Values = append(table.Values[:at:at], table.Values[at+1:]...)
+    table.Hashes = append(table.Hashes[:at:at], table.Hashes[at+1:]...)
+}
+
+// Returns a new table with the same keys as table, bound to values.
+func (table *Table) withValues(values []Receiver) *Table {
+    index := make(map[uint64][]int, len(table.Index))
+    for h, positions := range table.Index {
+        index[h] = append([]int(nil), positions...)
+    }
+    keys := append([]Receiver(nil), table.Keys...)
+    hashes := append([]uint64(nil), table.Hashes...)
+    return &Table { keys, values, index, hashes }
+}
+
+func (table *Table) copy() *Table {
+    return table.withValues(append([]Receiver(nil), table.Values...))
+}
+
+func newTable(vm *TenoriteVM, keys, values []Receiver) (*Table, error) {
+    table := &Table {
+        make([]Receiver, 0, len(keys)),
+        make([]Receiver, 0, len(keys)),
+        make(map[uint64][]int, len(keys)),
+        make([]uint64, 0, len(keys)),
+    }
+    for i, key := range keys {
+        err := tableSet(vm, table, key, values[i])
+        if err != nil { return nil, err }
+    }
+    return table, nil
+}
@@ -5,6 +5,8 @@ type TenoriteVM struct {
     Modules       map[string]*Module
     TopModule     *Module
     Error         error
+    Raised        *ErrorObj
+    Coroutine     *Coroutine
 
     StackTrace    bool
 }
@@ -9,10 +9,15 @@ chunk ::= stat `.´ [`loop´]
 
 stat ::=
      [`nonlocal´] Name `:=´ exp | 
+     [`nonlocal´] pattern `:=´ exp | 
      `type´ Name `:=´ Name | 
      `type´ Name `fn´ Name params `{´ chunk `}´ | 
      Name `fn´ Name params `{´ chunk `}´ | 
      `if´ exp `return´ exp | 
+     `^´ exp | 
+     `for´ Name `in´ exp `{´ chunk `}´ | 
+     `while´ exp `{´ chunk `}´ | 
+     ifexp | 
      exp | 
 
 params ::= Name | {Key Name}
@@ -22,6 +27,8 @@ binexp ::= unexp [rank] {Binop [rank] unexp}
 unexp ::= term { [rank] (Name | `[´ exp `]´) }
 
 rank ::= `@´ [Number]
+
+ifexp ::= `if´ exp `{´ chunk `}´ [`else´ (ifexp | `{´ chunk `}´)]
     
 term ::=
     Number | 
@@ -31,12 +38,17 @@ term ::=
     `(´ exp `)´ | 
     function | 
     listliteral |
-    tableliteral
+    tableliteral |
+    recordliteral |
+    ifexp |
+    matchexp
 
 function ::= `{´ `|´ {Name} `|´ block `}´
 
 listliteral ::= `[´ [exp {`,´ exp}] `]´
 
+recordliteral ::= `[´ Key exp {`,´ Key exp} `]´
+
 tableliteral ::= `#´ `[´ [tableentry {`,´ tableentry} ] `]´
 
 tableentry ::=
@@ -46,6 +58,23 @@ tableentry ::=
 
 symbol ::= `#´Name | `#´Binop | `#´Key{Key}
 
+matchexp ::= `match´ exp `{´ {arm `.´} `}´
+arm ::= armpattern [`if´ exp] `->´ (exp | `{´ chunk `}´)
+
+armpattern ::=
+    `_´ | 
+    Name [`type´ term] | 
+    Number | String | symbol | 
+    `[´ [armpattern {`,´ armpattern}] [`,´ `...´Name] `]´ | 
+    Regex [`[´ ... `]´] | 
+    armpattern `=>´ armpattern
+
+pattern ::=
+    Name | 
+    `[´ [pattern {`,´ pattern}] [`,´ `...´Name] `]´ | 
+    pattern `=>´ pattern | 
+    `#´ `[´ [(Key | `(´ exp `)´ `:´) pattern {`.´ ...}] `]´
+
 */
 
 type Printer interface {
@@ -75,40 +104,78 @@ type TableEntry struct {
 }
 
 type Stmt interface {
+    Line() int
+    Span() token.Span
     stmtNode()
 }
 
 type AssignStmt struct {
     NonLocal   bool
     Name       Name
-    AssignPos  int
+    AssignPos  token.Pos
     Value      Expr
 }
 
 type FieldAssignStmt struct {
     Name       Field
-    AssignPos  int
+    AssignPos  token.Pos
     Value      Expr
 }
 
 type MethStmt struct {
     Namespace  Name
-    FnPos      int
+    FnPos      token.Pos
     Params     Expr
     Body       Chunk
+    Rblock     token.Pos
 }
 
 type TypeStmt struct {
-    Type       int
+    Type       token.Pos
     Namespace  token.Token
 }
 
 type ReturnStmt struct {
+    If      token.Pos
     Cond    Expr
     Return  Expr
 }
 
-type LoopStmt struct { }
+type NonLocalReturnStmt struct {
+    Caret   token.Pos
+    Return  Expr
+}
+
+type YieldStmt struct {
+    Yield   token.Pos
+    Value   Expr
+}
+
+type LoopStmt struct {
+    Loop    token.Token
+}
+
+type ForStmt struct {
+    For     token.Pos
+    Name    Name
+    Seq     Expr
+    Body    Chunk
+    Rblock  token.Pos
+}
+
+type WhileStmt struct {
+    While   token.Pos
+    Cond    Expr
+    Body    Chunk
+    Rblock  token.Pos
+}
+
+type DestructureStmt struct {
+    NonLocal   bool
+    Pattern    Pattern
+    AssignPos  token.Pos
+    Value      Expr
+}
 
 type ExprStmt struct {
     X  Expr
@@ -119,13 +186,45 @@ func (_ FieldAssignStmt) stmtNode() {}
 func (_ MethStmt) stmtNode()        {}
 func (_ TypeStmt) stmtNode()        {}
 func (_ ReturnStmt) stmtNode()      {}
+func (_ NonLocalReturnStmt) stmtNode() {}
+func (_ YieldStmt) stmtNode()       {}
 func (_ LoopStmt) stmtNode()        {}
+func (_ ForStmt) stmtNode()         {}
+func (_ WhileStmt) stmtNode()       {}
+func (_ DestructureStmt) stmtNode() {}
 func (_ ExprStmt) stmtNode()        {}
 
+func (stmt AssignStmt) Span() token.Span       { return spanOf(stmt.Name.Span(), stmt.Value.Span()) }
+func (stmt FieldAssignStmt) Span() token.Span  { return spanOf(stmt.Name.Span(), stmt.Value.Span()) }
+func (stmt MethStmt) Span() token.Span         { return token.Span { Start: stmt.Namespace.Span().Start, End: closing(stmt.Rblock) } }
+func (stmt TypeStmt) Span() token.Span         { return token.Span { Start: stmt.Type, End: stmt.Namespace.End } }
+func (stmt ReturnStmt) Span() token.Span       { return token.Span { Start: stmt.If, End: stmt.Return.Span().End } }
+func (stmt NonLocalReturnStmt) Span() token.Span { return token.Span { Start: stmt.Caret, End: stmt.Return.Span().End } }
+func (stmt YieldStmt) Span() token.Span        { return token.Span { Start: stmt.Yield, End: stmt.Value.Span().End } }
+func (stmt LoopStmt) Span() token.Span         { return stmt.Loop.Span() }
+func (stmt ForStmt) Span() token.Span          { return token.Span { Start: stmt.For, End: closing(stmt.Rblock) } }
+func (stmt WhileStmt) Span() token.Span        { return token.Span { Start: stmt.While, End: closing(stmt.Rblock) } }
+func (stmt DestructureStmt) Span() token.Span  { return spanOf(stmt.Pattern.Span(), stmt.Value.Span()) }
+func (stmt ExprStmt) Span() token.Span         { return stmt.X.Span() }
+
+func (stmt AssignStmt) Line() int       { return stmt.Span().Start.Line }
+func (stmt FieldAssignStmt) Line() int  { return stmt.Span().Start.Line }
+func (stmt MethStmt) Line() int         { return stmt.Span().Start.Line }
+func (stmt TypeStmt) Line() int         { return stmt.Span().Start.Line }
+func (stmt ReturnStmt) Line() int       { return stmt.Span().Start.Line }
+func (stmt NonLocalReturnStmt) Line() int { return stmt.Span().Start.Line }
+func (stmt YieldStmt) Line() int        { return stmt.Span().Start.Line }
+func (stmt LoopStmt) Line() int         { return stmt.Span().Start.Line }
+func (stmt ForStmt) Line() int          { return stmt.Span().Start.Line }
+func (stmt WhileStmt) Line() int        { return stmt.Span().Start.Line }
+func (stmt DestructureStmt) Line() int  { return stmt.Span().Start.Line }
+func (stmt ExprStmt) Line() int         { return stmt.Span().Start.Line }
+
 
 type Expr interface {
     // Print(int)
     Line() int
+    Span() token.Span
     exprNode()
 }
 
@@ -136,9 +235,9 @@ type CallExpr struct {
 }
 
 type StringInterpExpr struct {
-    Lquote  int
+    Lquote  token.Pos
     Parts   []Expr
-    Rquote  int
+    Rquote  token.Pos
 }
 
 type BinaryExpr struct {
@@ -157,34 +256,48 @@ type UnaryExpr struct {
 
 type IndexExpr struct {
     X       Expr
-    Lbrack  int
+    Lbrack  token.Pos
     Y       Expr
-    Rbrack  int
+    Rbrack  token.Pos
 }
 
 type ParenExpr struct {
-    Lparen  int
+    Lparen  token.Pos
     X       Expr
-    Rparen  int
+    Rparen  token.Pos
 }
 
 type ListLiteral struct {
-    Lbrack  int
+    Lbrack  token.Pos
     List    []Expr
-    Rbrack  int
+    Rbrack  token.Pos
 }
 
 type TableLiteral struct {
-    Lbrack  int
+    Lbrack  token.Pos
     Items   []TableEntry
-    Rbrack  int
+    Rbrack  token.Pos
+}
+
+type RecordLiteral struct {
+    Lbrack  token.Pos
+    Fields  []KeyValue
+    Rbrack  token.Pos
 }
 
 type FunctionLiteral struct {
-    Lblock  int
+    Lblock  token.Pos
     Params  []Name
     Body    Chunk
-    Rblock  int
+    Rblock  token.Pos
+}
+
+type IfExpr struct {
+    If      token.Pos
+    Cond    Expr
+    Body    Chunk
+    Else    Chunk
+    Rblock  token.Pos
 }
 
 type Name struct {
@@ -208,6 +321,24 @@ type BasicLiteral struct {
     Value  string
 }
 
+type MatchArm struct {
+    Pattern  Pattern
+    Guard    Expr
+    Body     Chunk
+}
+
+type MatchExpr struct {
+    Match    token.Pos
+    Subject  Expr
+    Arms     []MatchArm
+    Rblock   token.Pos
+}
+
+type SpreadExpr struct {
+    Ellipsis  token.Pos
+    Name      Name
+}
+
 func (_ CallExpr) exprNode()          {}
 func (_ BinaryExpr) exprNode()        {}
 func (_ UnaryExpr) exprNode()         {}
@@ -218,22 +349,119 @@ func (_ Name) exprNode()              {}
 func (_ Field) exprNode()             {}
 func (_ ListLiteral) exprNode()       {}
 func (_ TableLiteral) exprNode()      {}
+func (_ RecordLiteral) exprNode()     {}
 func (_ BasicLiteral) exprNode()      {}
 func (_ Binop) exprNode()             {}
 func (_ Symbol) exprNode()            {}
 func (_ StringInterpExpr) exprNode()  {}
+func (_ IfExpr) exprNode()            {}
+func (_ MatchExpr) exprNode()         {}
+func (_ SpreadExpr) exprNode()        {}
+
+func (expr CallExpr) Span() token.Span {
+    return spanOf(expr.Recv.Span(), expr.Args[len(expr.Args)-1].Value.Span())
+}
+func (expr BinaryExpr) Span() token.Span        { return spanOf(expr.X.Span(), expr.Y.Span()) }
+func (expr UnaryExpr) Span() token.Span         { return spanOf(expr.X.Span(), expr.Method.Span()) }
+func (expr IndexExpr) Span() token.Span         { return token.Span { Start: expr.X.Span().Start, End: closing(expr.Rbrack) } }
+func (expr ParenExpr) Span() token.Span         { return token.Span { Start: expr.Lparen, End: closing(expr.Rparen) } }
+func (expr FunctionLiteral) Span() token.Span   { return token.Span { Start: expr.Lblock, End: closing(expr.Rblock) } }
+func (expr Name) Span() token.Span              { return expr.Value.Span() }
+func (expr Field) Span() token.Span             { return expr.Value.Span() }
+func (expr ListLiteral) Span() token.Span       { return token.Span { Start: expr.Lbrack, End: closing(expr.Rbrack) } }
+func (expr TableLiteral) Span() token.Span      { return token.Span { Start: expr.Lbrack, End: closing(expr.Rbrack) } }
+func (expr RecordLiteral) Span() token.Span     { return token.Span { Start: expr.Lbrack, End: closing(expr.Rbrack) } }
+func (expr BasicLiteral) Span() token.Span      { return expr.Kind.Span() }
+func (expr Binop) Span() token.Span             { return expr.Op.Span() }
+func (expr Symbol) Span() token.Span            { return expr.Value.Span() }
+func (expr StringInterpExpr) Span() token.Span  { return token.Span { Start: expr.Lquote, End: closing(expr.Rquote) } }
+func (expr IfExpr) Span() token.Span            { return token.Span { Start: expr.If, End: closing(expr.Rblock) } }
+func (expr MatchExpr) Span() token.Span         { return token.Span { Start: expr.Match, End: closing(expr.Rblock) } }
+func (expr SpreadExpr) Span() token.Span        { return token.Span { Start: expr.Ellipsis, End: expr.Name.Span().End } }
+
+func (expr CallExpr) Line() int          { return expr.Span().Start.Line }
+func (expr BinaryExpr) Line() int        { return expr.Span().Start.Line }
+func (expr UnaryExpr) Line() int         { return expr.Span().Start.Line }
+func (expr IndexExpr) Line() int         { return expr.Span().Start.Line }
+func (expr ParenExpr) Line() int         { return expr.Span().Start.Line }
+func (expr FunctionLiteral) Line() int   { return expr.Span().Start.Line }
+func (expr Name) Line() int              { return expr.Span().Start.Line }
+func (expr Field) Line() int             { return expr.Span().Start.Line }
+func (expr ListLiteral) Line() int       { return expr.Span().Start.Line }
+func (expr TableLiteral) Line() int      { return expr.Span().Start.Line }
+func (expr RecordLiteral) Line() int     { return expr.Span().Start.Line }
+func (expr BasicLiteral) Line() int      { return expr.Span().Start.Line }
+func (expr Binop) Line() int             { return expr.Span().Start.Line }
+func (expr Symbol) Line() int            { return expr.Span().Start.Line }
+func (expr StringInterpExpr) Line() int  { return expr.Span().Start.Line }
+func (expr IfExpr) Line() int            { return expr.Span().Start.Line }
+func (expr MatchExpr) Line() int         { return expr.Span().Start.Line }
+func (expr SpreadExpr) Line() int        { return expr.Span().Start.Line }
+
+type Pattern interface {
+    Span() token.Span
+    patternNode()
+}
+
+type ListPattern struct {
+    Lbrack  token.Pos
+    Items   []Pattern
+    Rest    *Name
+    Rbrack  token.Pos
+}
+
+type PairPattern struct {
+    Key    Pattern
+    Value  Pattern
+}
+
+type TablePatternEntry struct {
+    Key    Expr
+    Value  Pattern
+}
+
+type TablePattern struct {
+    Lbrack   token.Pos
+    Entries  []TablePatternEntry
+    Rbrack   token.Pos
+}
+
+type LiteralPattern struct {
+    Value  Expr
+}
+
+type TypePattern struct {
+    Name   Name
+    Type   Expr
+}
 
-func (expr CallExpr) Line() int          { return expr.Args[0].Key.Value.Line }
-func (expr BinaryExpr) Line() int        { return expr.Op.Op.Line }
-func (expr UnaryExpr) Line() int         { return expr.Method.Value.Line }
-func (expr IndexExpr) Line() int         { return expr.X.Line() }
-func (expr ParenExpr) Line() int         { return expr.X.Line() }
-func (expr FunctionLiteral) Line() int   { return expr.Lblock }
-func (expr Name) Line() int              { return expr.Value.Line }
-func (expr Field) Line() int             { return expr.Value.Line }
-func (expr ListLiteral) Line() int       { return expr.Lbrack }
-func (expr TableLiteral) Line() int      { return expr.Lbrack }
-func (expr BasicLiteral) Line() int      { return expr.Kind.Line }
-func (expr Binop) Line() int             { return expr.Op.Line }
-func (expr Symbol) Line() int            { return expr.Value.Line }
-func (expr StringInterpExpr) Line() int  { return expr.Lquote }
\ No newline at end of file
+type RegexPattern struct {
+    Regex   BasicLiteral
+    Groups  *ListPattern
+}
+
+func (_ Name) patternNode()            {}
+func (_ ListPattern) patternNode()     {}
+func (_ PairPattern) patternNode()     {}
+func (_ TablePattern) patternNode()    {}
+func (_ LiteralPattern) patternNode()  {}
+func (_ TypePattern) patternNode()     {}
+func (_ RegexPattern) patternNode()    {}
+
+func (pat ListPattern) Span() token.Span     { return token.Span { Start: pat.Lbrack, End: closing(pat.Rbrack) } }
+func (pat PairPattern) Span() token.Span     { return spanOf(pat.Key.Span(), pat.Value.Span()) }
+func (pat TablePattern) Span() token.Span    { return token.Span { Start: pat.Lbrack, End: closing(pat.Rbrack) } }
+func (pat LiteralPattern) Span() token.Span  { return pat.Value.Span() }
+func (pat TypePattern) Span() token.Span     { return spanOf(pat.Name.Span(), pat.Type.Span()) }
+func (pat RegexPattern) Span() token.Span {
+    if pat.Groups != nil { return spanOf(pat.Regex.Span(), pat.Groups.Span()) }
+    return pat.Regex.Span()
+}
+
+func spanOf(first, last token.Span) token.Span {
+    return token.Span { Start: first.Start, End: last.End }
+}
+
+func closing(pos token.Pos) token.Pos {
+    return token.Pos { Line: pos.Line, Column: pos.Column+1, Offset: pos.Offset+1 }
+}
@@ -1,20 +1,29 @@
 package parser
 
 import (
-	"fmt"
+	"errors"
+	"math"
 	"strconv"
+	"unicode"
 	"0Walle/Tenorite/token"
 )
 
 type Parser struct {
-	Tokens []token.Token
-	i      int
-	Line   int
-	Err    error
+	Tokens      []token.Token
+	File        string
+	i           int
+	Line        int
+	Err         error
+	Diagnostics []token.Diagnostic
+	inGuard     bool
+	recovered   int
 }
 
-func NewParser(tokens []token.Token) Parser {
-	return Parser { tokens, 0, 1, nil }
+// Stands for an error the scanner has already reported.
+var errScanned = errors.New("error reported by the scanner")
+
+func NewParser(tokens []token.Token, file string) Parser {
+	return Parser { tokens, file, 0, 1, nil, nil, false, -1 }
 }
 
 func (p *Parser) Peek() *token.Token {
@@ -49,24 +58,69 @@ func (p *Parser) Consume(kind token.TokenKind, message string) *token.Token {
 }
 
 func (p *Parser) Error(where *token.Token, message string) error {
-	return fmt.Errorf("Line %d near '%s': %s", where.Line, where.Lexeme, message)
+	return token.NewDiagnostic(p.File, *where, message)
+}
+
+// Errors at the token the parser recovered at are follow-ons of the error it
+// recovered from and are dropped.
+func (p *Parser) Report(err error) {
+	if err == errScanned { return }
+	diag, ok := err.(token.Diagnostic)
+	if !ok {
+		diag = token.NewDiagnostic(p.File, *p.Peek(), err.Error())
+	}
+	if p.recovered != -1 {
+		at := p.Tokens[p.recovered]
+		if diag.Line == at.Line && diag.Column == at.Column { return }
+	}
+	p.Diagnostics = append(p.Diagnostics, diag)
+}
+
+func (p *Parser) Synchronize() {
+	defer func() { p.recovered = p.i }()
+
+	depth := 0
+	for !p.IsAtEnd() {
+		switch p.Peek().Kind {
+		case token.LEFT_BLOCK:
+			depth += 1
+		case token.RIGHT_BLOCK:
+			if depth == 0 { return }
+			depth -= 1
+		case token.TERMINATOR:
+			if depth == 0 { return }
+		}
+		p.Advance()
+	}
 }
 
 // ====== Parsing Methods ======
 
-func (p *Parser) ParseUnit() (Unit, error) {
+func (p *Parser) ParseUnit() (Unit, []token.Diagnostic) {
 	var unit Unit
 
 	for !p.IsAtEnd() {
 		stmt, err := p.ParseStmt()
-		if err != nil { return unit, err }
-
-		unit.Contents = append(unit.Contents, stmt)
+		if err != nil {
+			p.Report(err)
+			p.Synchronize()
+			if p.Check(token.RIGHT_BLOCK) {
+				p.Report(p.Error(p.Advance(), "Unexpected `}´"))
+				p.Synchronize()
+			}
+		} else {
+			unit.Contents = append(unit.Contents, stmt)
+		}
 
-		if p.Consume(token.TERMINATOR, "'.' at end of statement.") == nil { return unit, p.Err }
+		if p.IsAtEnd() { break }
+		if p.Consume(token.TERMINATOR, "'.' at end of statement.") == nil {
+			p.Report(p.Err)
+			p.Synchronize()
+			if p.Check(token.TERMINATOR) { p.Advance() }
+		}
 	}
 
-	return unit, nil
+	return unit, p.Diagnostics
 }
 
 func (p *Parser) ParseStmt() (Stmt, error) {
@@ -74,35 +128,74 @@ func (p *Parser) ParseStmt() (Stmt, error) {
 	var nonlocal bool
 
 	if p.Check(token.IF) {
-		p.Advance()
+		if_kw := p.Advance()
 		cond, err := p.ParseExpr()
 		if err != nil { return stmt, err }
-		if p.Consume(token.RETURN, "`return´") == nil {
+		if p.Check(token.LEFT_BLOCK) {
+			expr, err := p.ParseIfExpr(if_kw, cond)
+			if err != nil { return stmt, err }
+			return ExprStmt { expr }, nil
+		}
+		if p.Consume(token.RETURN, "`return´ or `{´") == nil {
 			return stmt, p.Err
 		}
 		retval, err := p.ParseExpr()
 		if err != nil { return stmt, err }
-		return ReturnStmt{ cond, retval }, nil
+		return ReturnStmt{ if_kw.Pos(), cond, retval }, nil
+	} else if p.Check(token.OPERATOR) && p.Peek().Lexeme == "^" {
+		caret := p.Advance()
+		retval, err := p.ParseExpr()
+		if err != nil { return stmt, err }
+		return NonLocalReturnStmt { caret.Pos(), retval }, nil
+	} else if p.Check(token.YIELD) {
+		yield_kw := p.Advance()
+		value, err := p.ParseExpr()
+		if err != nil { return stmt, err }
+		return YieldStmt { yield_kw.Pos(), value }, nil
 	} else if p.Check(token.NONLOCAL) {
 		p.Advance()
 		nonlocal = true
 	} else if p.Check(token.LOOP) {
-		p.Advance()
-		return LoopStmt {}, nil
+		loop_kw := p.Advance()
+		return LoopStmt { *loop_kw }, nil
+	} else if p.Check(token.FOR) {
+		for_kw := p.Advance()
+
+		name := p.Consume(token.NAME, "loop variable")
+		if name == nil { return nil, p.Err }
+		if p.Consume(token.IN, "`in´") == nil { return nil, p.Err }
+
+		seq, err := p.ParseExpr()
+		if err != nil { return stmt, err }
+
+		body, err := p.ParseBlock()
+		if err != nil { return stmt, err }
+
+		return ForStmt { for_kw.Pos(), Name { *name }, seq, body, p.Previous().Pos() }, nil
+	} else if p.Check(token.WHILE) {
+		while_kw := p.Advance()
+
+		cond, err := p.ParseExpr()
+		if err != nil { return stmt, err }
+
+		body, err := p.ParseBlock()
+		if err != nil { return stmt, err }
+
+		return WhileStmt { while_kw.Pos(), cond, body, p.Previous().Pos() }, nil
 	} else if p.Check(token.TYPE) {
 		type_kw := p.Advance()
 		
 		ns := p.Consume(token.NAME, "Namespace")
 		if ns == nil { return nil, p.Err }
 		
-		return TypeStmt { type_kw.Line, *ns }, nil
+		return TypeStmt { type_kw.Pos(), *ns }, nil
 	}
 	
 	expr, err := p.ParseExpr()
 	if err != nil { return stmt, err }
 
 	if nonlocal && !p.Check(token.ASSIGN) {
-		return stmt, fmt.Errorf("Expected `:=` in nonlocal assignment")
+		return stmt, p.Error(p.Peek(), "Expected `:=` in nonlocal assignment")
 	}
 
 	if p.Check(token.ASSIGN) {
@@ -111,25 +204,30 @@ func (p *Parser) ParseStmt() (Stmt, error) {
 		if field, ok := expr.(Field); ok {
 			expr, err := p.ParseExpr()
 			if err != nil { return stmt, err }
-			stmt = FieldAssignStmt { field, assignPos.Line, expr }
+			stmt = FieldAssignStmt { field, assignPos.Pos(), expr }
 			return stmt, nil
 		}
 
 		name, ok := expr.(Name)
 		if !ok {
-			return stmt, fmt.Errorf("Invalid assignment, expected identifier")
+			pattern, err := p.ToPattern(expr)
+			if err != nil { return stmt, err }
+			value, err := p.ParseExpr()
+			if err != nil { return stmt, err }
+			return DestructureStmt { nonlocal, pattern, assignPos.Pos(), value }, nil
 		}
 		expr, err := p.ParseExpr()
 		if err != nil { return stmt, err }
-		stmt = AssignStmt { nonlocal, name, assignPos.Line, expr }
+		stmt = AssignStmt { nonlocal, name, assignPos.Pos(), expr }
 	} else if p.Check(token.FN) {
-		p.Advance()
+		fn_kw := p.Advance()
 		ns, ok := expr.(Name)
 		if !ok {
-			return stmt, fmt.Errorf("Invalid assignment, expected identifier")
+			return stmt, p.Error(fn_kw, "Invalid method definition, expected namespace")
 		}
 		stmt, err := p.ParseMethodStmt()
 		stmt.Namespace = ns
+		stmt.FnPos = fn_kw.Pos()
 		if err != nil { return stmt, err }
 		return stmt, nil
 	} else {
@@ -176,23 +274,77 @@ func (p *Parser) ParseMethodStmt() (MethStmt, error) {
 		}
 	}
 
-	if p.Consume(token.LEFT_BLOCK, "`{´") == nil { return meth, p.Err }
+	body, err := p.ParseBlock()
+	if err != nil { return meth, err }
+	meth.Body = body
+	meth.Rblock = p.Previous().Pos()
 
-	for !p.Check(token.RIGHT_BLOCK) {
-		if meth.Body != nil {
-			if p.Consume(token.TERMINATOR, "`.´ separator.") == nil { return meth, p.Err }
-			if p.Check(token.RIGHT_BLOCK) { break }
-		}
+	return meth, nil
+}
 
+func (p *Parser) ParseBody() Chunk {
+	var body Chunk
+
+	for !p.Check(token.RIGHT_BLOCK) && !p.IsAtEnd() {
 		stmt, err := p.ParseStmt()
-		if err != nil { return meth, err }
+		if err != nil {
+			p.Report(err)
+			p.Synchronize()
+		} else {
+			body = append(body, stmt)
+		}
 
-		meth.Body = append(meth.Body, stmt)
+		if p.Check(token.RIGHT_BLOCK) || p.IsAtEnd() { break }
+		if p.Consume(token.TERMINATOR, "`.´ separator.") == nil {
+			p.Report(p.Err)
+			p.Synchronize()
+			if p.Check(token.TERMINATOR) { p.Advance() }
+		}
 	}
 
-	p.Advance()
+	return body
+}
 
-	return meth, nil
+func (p *Parser) ParseBlock() (Chunk, error) {
+	if p.Consume(token.LEFT_BLOCK, "`{´") == nil { return nil, p.Err }
+
+	body := p.ParseBody()
+
+	if p.Consume(token.RIGHT_BLOCK, "`}´") == nil { return body, p.Err }
+
+	return body, nil
+}
+
+func (p *Parser) ParseIfExpr(if_kw *token.Token, cond Expr) (Expr, error) {
+	var expr IfExpr
+
+	expr.If = if_kw.Pos()
+	expr.Cond = cond
+
+	body, err := p.ParseBlock()
+	if err != nil { return expr, err }
+	expr.Body = body
+
+	if p.Check(token.ELSE) {
+		p.Advance()
+
+		if p.Check(token.IF) {
+			if_kw := p.Advance()
+			cond, err := p.ParseExpr()
+			if err != nil { return expr, err }
+			elseif, err := p.ParseIfExpr(if_kw, cond)
+			if err != nil { return expr, err }
+			expr.Else = Chunk { ExprStmt { elseif } }
+		} else {
+			body, err := p.ParseBlock()
+			if err != nil { return expr, err }
+			expr.Else = body
+		}
+	}
+
+	expr.Rblock = p.Previous().Pos()
+
+	return expr, nil
 }
 
 func (p *Parser) ParseExpr() (Expr, error) {
@@ -229,14 +381,14 @@ func (p *Parser) ParseExpr() (Expr, error) {
 
 			value, r, err := p.ParseBinExpr()
 			if err != nil { return expr, err }
-			if r != 0 { return expr, fmt.Errorf("Cannot have rank here") }
+			if r != 0 { return expr, p.Error(p.Previous(), "Cannot have rank here") }
 			args = append(args, KeyValue { Key { *key }, value, rank })
 		}
 
 		if args != nil {
 			expr = CallExpr { expr, 0, args }
 		} else {
-			p.Error(cascadeTk, "Unexpected Token `:>´")
+			return expr, p.Error(cascadeTk, "Unexpected Token `:>´")
 		}
 	}
 
@@ -258,7 +410,7 @@ func (p *Parser) ParseCallExpr() (Expr, error) {
 
 		value, r, err := p.ParseBinExpr()
 		if err != nil { return expr, err }
-		if r != 0 { return expr, fmt.Errorf("Cannot have rank here") }
+		if r != 0 { return expr, p.Error(p.Previous(), "Cannot have rank here") }
 		args = append(args, KeyValue { Key { *key }, value, rank })
 	}
 
@@ -266,7 +418,7 @@ func (p *Parser) ParseCallExpr() (Expr, error) {
 		expr = CallExpr { recv, xrank, args }
 	} else {
 		if xrank != 0 {
-			return expr, fmt.Errorf("Cannot have rank here")
+			return expr, p.Error(p.Previous(), "Cannot have rank here")
 		}
 		expr = recv
 	}
@@ -284,6 +436,9 @@ func (p *Parser) ParseBinExpr() (Expr, int, error) {
 		if !(p.Check(token.OPERATOR) || p.Check(token.TYPE)) {
 			break
 		}
+		if p.inGuard && p.Peek().Lexeme == "->" {
+			break
+		}
 
 		op := p.Advance()
 
@@ -335,30 +490,36 @@ func (p *Parser) ParseUnExpr() (Expr, int, error) {
 			index, err := p.ParseExpr()
 			if err != nil { return expr, rank, err }
 			if p.Consume(token.RIGHT_LIST, "closing bracket.") == nil { return nil, rank, p.Err }
-			expr = IndexExpr { expr, lbrack.Line, index, p.Line }
+			expr = IndexExpr { expr, lbrack.Pos(), index, p.Previous().Pos() }
 			continue
 		}
 
 		return expr, rank, nil
 	}
-	
-	return expr, 0, nil
 }
 
 func (p *Parser) ParseTerm() (Expr, error) {
+	if p.IsAtEnd() {
+		return nil, p.Error(p.Peek(), "Unexpected end of file")
+	}
+
 	tk := p.Advance()
 
 	if tk.Kind == token.NUMBER { return BasicLiteral { *tk, tk.Value }, nil }
 	if tk.Kind == token.RAW_STRING { return BasicLiteral { *tk, tk.Value }, nil }
 	if tk.Kind == token.REGEX { return BasicLiteral { *tk, tk.Value }, nil }
 	if tk.Kind == token.SYMBOL { return Symbol { *tk }, nil }
+	if tk.Kind == token.NAME && tk.Lexeme == "match" && p.isMatchExpr() {
+		return p.ParseMatchExpr(tk)
+	}
 	if tk.Kind == token.NAME { return Name { *tk }, nil }
 	if tk.Kind == token.FIELD { return Field { *tk }, nil }
 
 	if tk.Kind == token.STRING_BEGIN {
-		lquote := tk.Line
+		lquote := tk.Pos()
 		var parts []Expr
 		for !p.Check(token.STRING_END) {
+			if p.IsAtEnd() { return nil, errScanned }
 
 			if p.Check(token.STRING_LITERAL) {
 				tk = p.Advance()
@@ -369,20 +530,27 @@ func (p *Parser) ParseTerm() (Expr, error) {
 			if p.Check(token.LEFT_BLOCK) {
 				p.Advance()
 				expr, err := p.ParseExpr()
+				if err != nil && p.IsAtEnd() { return nil, errScanned }
 				if err != nil { return expr, err }
 				parts = append(parts, expr )
 
 				if p.Consume(token.RIGHT_BLOCK, "closing bracket") == nil { return expr, p.Err }
 			}
 		}
-		rquote := p.Advance().Line
+		rquote := p.Advance().Pos()
 
 		return StringInterpExpr { lquote, parts, rquote }, nil
 
 	}
 
+	if tk.Kind == token.IF {
+		cond, err := p.ParseExpr()
+		if err != nil { return cond, err }
+		return p.ParseIfExpr(tk, cond)
+	}
+
 	if tk.Kind == token.LEFT_PAREN {
-		lparen := tk.Line
+		lparen := tk.Pos()
 		expr, err := p.ParseExpr()
 		if err != nil { return expr, err }
 
@@ -390,7 +558,7 @@ func (p *Parser) ParseTerm() (Expr, error) {
 			return nil, p.Err
 		}
 
-		return ParenExpr { lparen, expr, p.Line }, nil
+		return ParenExpr { lparen, expr, p.Previous().Pos() }, nil
 	}
 
 	if tk.Kind == token.LEFT_BLOCK {
@@ -407,27 +575,33 @@ func (p *Parser) ParseTerm() (Expr, error) {
 			p.Advance()
 		}
 
-		var body Chunk
-		for !p.Check(token.RIGHT_BLOCK) {
-			if body != nil {
-				if p.Consume(token.TERMINATOR, "`.´ separator.") == nil { return nil, p.Err }
-				if p.Check(token.RIGHT_BLOCK) { break }
-			}
-
-			stmt, err := p.ParseStmt()
-			if err != nil { return nil, err }
-
-			body = append(body, stmt)
-		}
-		rblock := p.Advance().Line
+		body := p.ParseBody()
+		if p.Consume(token.RIGHT_BLOCK, "closing `}´") == nil { return nil, p.Err }
+		rblock := p.Previous().Pos()
 
-		return FunctionLiteral { lblock.Line, params, body, rblock }, nil
+		return FunctionLiteral { lblock.Pos(), params, body, rblock }, nil
 	}
 
 	if tk.Kind == token.LEFT_LIST {
-		lbrack := tk.Line
+		lbrack := tk.Pos()
 		if p.Check(token.KEY) {
-			return nil, p.Error(tk, "Uninplemented")
+			var fields []KeyValue
+			for !p.Check(token.RIGHT_LIST) {
+				if fields != nil {
+					if p.Consume(token.SEPARATOR, "`,´ separator.") == nil { return nil, p.Err }
+					if p.Check(token.RIGHT_LIST) { break }
+				}
+
+				key := p.Consume(token.KEY, "field name.")
+				if key == nil { return nil, p.Err }
+				value, err := p.ParseExpr()
+				if err != nil { return nil, err }
+				fields = append(fields, KeyValue { Key { *key }, value, 0 })
+			}
+
+			rbrack := p.Advance().Pos()
+
+			return RecordLiteral { lbrack, fields, rbrack }, nil
 		}
 
 		var items []Expr
@@ -437,21 +611,29 @@ func (p *Parser) ParseTerm() (Expr, error) {
 				if p.Check(token.RIGHT_LIST) { break }
 			}
 
+			if p.Check(token.ELLIPSIS) {
+				ellipsis := p.Advance()
+				name := p.Consume(token.NAME, "name after `...´")
+				if name == nil { return nil, p.Err }
+				items = append(items, SpreadExpr { ellipsis.Pos(), Name { *name } })
+				continue
+			}
+
 			expr, err := p.ParseExpr()
 			if err != nil { return nil, err }
 			items = append(items, expr)
 		}
 
-		rbrack := p.Advance().Line
+		rbrack := p.Advance().Pos()
 
 		return ListLiteral { lbrack, items, rbrack }, nil
 	}
 
 	if tk.Kind == token.HASH {
+		lbrack := tk.Pos()
 		tk := p.Advance()
 
 		if tk.Kind == token.LEFT_LIST {
-			lbrack := tk.Line
 		
 			var items []TableEntry
 			for !p.Check(token.RIGHT_LIST) {
@@ -465,12 +647,17 @@ func (p *Parser) ParseTerm() (Expr, error) {
 				items = append(items, entry)
 			}
 
-			rbrack := p.Advance().Line
+			rbrack := p.Advance().Pos()
 
 			return TableLiteral { lbrack, items, rbrack }, nil
 		}
 	}
 
+	switch tk.Kind {
+	case token.TERMINATOR, token.RIGHT_BLOCK, token.RIGHT_LIST, token.RIGHT_PAREN:
+		p.i -= 1
+	}
+
 	return nil, p.Error(tk, "Unexpected Token `"+tk.Lexeme+"´")
 }
 
@@ -478,8 +665,9 @@ func (p *Parser) ParseTableEntry() (TableEntry, error) {
 	var entry TableEntry
 
 	if p.Check(token.KEY) {
-		key := p.Advance()
-		entry.Key = Symbol { token.Token { Value: key.Value[:len(key.Value)-1] } }
+		key := *p.Advance()
+		key.Value = key.Value[:len(key.Value)-1]
+		entry.Key = Symbol { key }
 		expr, err := p.ParseExpr()
 		if err != nil { return entry, err }
 		entry.Value = expr
@@ -504,18 +692,248 @@ func (p *Parser) ParseTableEntry() (TableEntry, error) {
 	return entry, p.Error(tk, "Unexpected Token `"+tk.Lexeme+"´ in table literal")
 }
 
+// `match´ is not reserved, it is also a common variable and method name.
+// It starts a match expression only when the subject is followed by a
+// block whose first arm has a `->´, so the lookahead ends with that arm.
+func (p *Parser) isMatchExpr() bool {
+	nesting, blocks := 0, 0
+	for i := p.i; i < len(p.Tokens); i++ {
+		tk := p.Tokens[i]
+		switch tk.Kind {
+		case token.EOF:
+			return false
+		case token.LEFT_PAREN, token.LEFT_LIST:
+			nesting += 1
+		case token.RIGHT_PAREN, token.RIGHT_LIST:
+			nesting -= 1
+			if nesting < 0 { return false }
+		case token.TERMINATOR:
+			if nesting == 0 && blocks <= 1 { return false }
+		case token.LEFT_BLOCK:
+			if nesting == 0 && blocks == 0 && i == p.i { return false }
+			blocks += 1
+		case token.RIGHT_BLOCK:
+			if blocks == 0 { return false }
+			blocks -= 1
+		case token.OPERATOR:
+			if nesting == 0 && blocks == 1 && tk.Lexeme == "->" { return true }
+		}
+	}
+	return false
+}
+
+func (p *Parser) ParseMatchExpr(match_kw *token.Token) (Expr, error) {
+	subject, err := p.ParseExpr()
+	if err != nil { return nil, err }
+
+	if p.Consume(token.LEFT_BLOCK, "`{´ after match subject") == nil { return nil, p.Err }
+
+	var arms []MatchArm
+	for {
+		for p.Check(token.TERMINATOR) { p.Advance() }
+		if p.Check(token.RIGHT_BLOCK) || p.IsAtEnd() { break }
+
+		var arm MatchArm
+		arm.Pattern, err = p.ParseArmPattern()
+		if err != nil { return nil, err }
+
+		if p.Check(token.IF) {
+			p.Advance()
+			p.inGuard = true
+			arm.Guard, err = p.ParseExpr()
+			p.inGuard = false
+			if err != nil { return nil, err }
+		}
+
+		if !p.Check(token.OPERATOR) || p.Peek().Lexeme != "->" {
+			return nil, p.Error(p.Peek(), "Expected `->´ after pattern")
+		}
+		p.Advance()
+
+		if p.Check(token.LEFT_BLOCK) {
+			arm.Body, err = p.ParseBlock()
+			if err != nil { return nil, err }
+		} else {
+			body, err := p.ParseExpr()
+			if err != nil { return nil, err }
+			arm.Body = Chunk { ExprStmt { body } }
+		}
+		arms = append(arms, arm)
+
+		if !p.Check(token.RIGHT_BLOCK) {
+			if p.Consume(token.TERMINATOR, "`.´ after match arm") == nil { return nil, p.Err }
+		}
+	}
+
+	if p.Consume(token.RIGHT_BLOCK, "closing `}´") == nil { return nil, p.Err }
+
+	return MatchExpr { match_kw.Pos(), subject, arms, p.Previous().Pos() }, nil
+}
+
+func (p *Parser) ParseArmPattern() (Pattern, error) {
+	var pattern Pattern
+
+	tk := p.Advance()
+	switch tk.Kind {
+	case token.NUMBER, token.RAW_STRING:
+		pattern = LiteralPattern { BasicLiteral { *tk, tk.Value } }
+	case token.STRING_BEGIN:
+		str, err := p.ParseStringPattern(tk)
+		if err != nil { return nil, err }
+		pattern = LiteralPattern { str }
+	case token.SYMBOL:
+		pattern = LiteralPattern { Symbol { *tk } }
+	case token.NAME:
+		name := Name { *tk }
+		if p.Check(token.TYPE) {
+			p.Advance()
+			typ, err := p.ParseTerm()
+			if err != nil { return nil, err }
+			pattern = TypePattern { name, typ }
+		} else if unicode.IsUpper([]rune(tk.Lexeme)[0]) {
+			pattern = LiteralPattern { name }
+		} else {
+			pattern = name
+		}
+	case token.LEFT_LIST:
+		list, err := p.ParseListArmPattern(tk)
+		if err != nil { return nil, err }
+		pattern = list
+	case token.REGEX:
+		regex := RegexPattern { Regex: BasicLiteral { *tk, tk.Value } }
+		if p.Check(token.LEFT_LIST) {
+			groups, err := p.ParseListArmPattern(p.Advance())
+			if err != nil { return nil, err }
+			regex.Groups = &groups
+		}
+		pattern = regex
+	case token.LEFT_PAREN:
+		inner, err := p.ParseArmPattern()
+		if err != nil { return nil, err }
+		if p.Consume(token.RIGHT_PAREN, "closing parenthesis") == nil { return nil, p.Err }
+		pattern = inner
+	default:
+		switch tk.Kind {
+		case token.TERMINATOR, token.RIGHT_BLOCK, token.RIGHT_LIST, token.RIGHT_PAREN:
+			p.i -= 1
+		}
+		return nil, p.Error(tk, "Unexpected Token `"+tk.Lexeme+"´ in pattern")
+	}
+
+	if p.Check(token.OPERATOR) && p.Peek().Lexeme == "=>" {
+		p.Advance()
+		value, err := p.ParseArmPattern()
+		if err != nil { return nil, err }
+		return PairPattern { pattern, value }, nil
+	}
+
+	return pattern, nil
+}
+
+// Only strings without interpolation are patterns.
+func (p *Parser) ParseStringPattern(lquote *token.Token) (BasicLiteral, error) {
+	lit := *lquote
+	lit.Kind = token.STRING_LITERAL
+	value := ""
+	for !p.Check(token.STRING_END) {
+		if p.IsAtEnd() { return BasicLiteral{}, errScanned }
+		if !p.Check(token.STRING_LITERAL) {
+			return BasicLiteral{}, p.Error(p.Peek(), "Interpolation is not allowed in a string pattern")
+		}
+		value += p.Advance().Value
+	}
+	lit.End = p.Advance().End
+	return BasicLiteral { lit, value }, nil
+}
+
+func (p *Parser) ParseListArmPattern(lbrack *token.Token) (ListPattern, error) {
+	list := ListPattern { Lbrack: lbrack.Pos() }
+	first := true
+	for !p.Check(token.RIGHT_LIST) {
+		if !first {
+			if p.Consume(token.SEPARATOR, "`,´ separator.") == nil { return list, p.Err }
+			if p.Check(token.RIGHT_LIST) { break }
+		}
+		first = false
+
+		if list.Rest != nil {
+			return list, p.Error(p.Peek(), "`...´ must be the last element of a list pattern")
+		}
+
+		if p.Check(token.ELLIPSIS) {
+			p.Advance()
+			name := p.Consume(token.NAME, "name after `...´")
+			if name == nil { return list, p.Err }
+			list.Rest = &Name { *name }
+			continue
+		}
+
+		item, err := p.ParseArmPattern()
+		if err != nil { return list, err }
+		list.Items = append(list.Items, item)
+	}
+	list.Rbrack = p.Advance().Pos()
+	return list, nil
+}
+
+func (p *Parser) ToPattern(expr Expr) (Pattern, error) {
+	switch expr := expr.(type) {
+	case Name:
+		return expr, nil
+	case ParenExpr:
+		return p.ToPattern(expr.X)
+	case ListLiteral:
+		pattern := ListPattern { Lbrack: expr.Lbrack, Rbrack: expr.Rbrack }
+		for i, item := range expr.List {
+			if spread, ok := item.(SpreadExpr); ok {
+				if i != len(expr.List)-1 {
+					return nil, token.SpanDiagnostic(p.File, item.Span(), "`...´ must be the last element of a list pattern")
+				}
+				rest := spread.Name
+				pattern.Rest = &rest
+				break
+			}
+			item, err := p.ToPattern(item)
+			if err != nil { return nil, err }
+			pattern.Items = append(pattern.Items, item)
+		}
+		return pattern, nil
+	case BinaryExpr:
+		if expr.Op.Op.Lexeme != "=>" || expr.XRank != 0 || expr.YRank != 0 {
+			break
+		}
+		key, err := p.ToPattern(expr.X)
+		if err != nil { return nil, err }
+		value, err := p.ToPattern(expr.Y)
+		if err != nil { return nil, err }
+		return PairPattern { key, value }, nil
+	case TableLiteral:
+		pattern := TablePattern { Lbrack: expr.Lbrack, Rbrack: expr.Rbrack }
+		for _, item := range expr.Items {
+			value, err := p.ToPattern(item.Value)
+			if err != nil { return nil, err }
+			pattern.Entries = append(pattern.Entries, TablePatternEntry { item.Key, value })
+		}
+		return pattern, nil
+	}
+	return nil, token.SpanDiagnostic(p.File, expr.Span(), "Invalid assignment, expected identifier or pattern")
+}
+
+// Ranks are shifted so that the zero value means no rank: a bare `@` is -1,
+// the same as `@-1`, other negative ranks are kept and `@k` is k+1.
 func (p *Parser) ParseRank() (int, error) {
 	rank := 0
 	if p.Check(token.AT) {
 		p.Advance()
-		rank = 1
+		rank = -1
 		if p.Check(token.NUMBER) {
 			tk := p.Advance()
-			n, err := strconv.ParseInt(tk.Value, 10, 64)
-			if err != nil {
-				return rank, err
+			n, err := strconv.ParseInt(tk.Value, 10, 16)
+			if err != nil || n >= math.MaxInt16 {
+				return rank, p.Error(tk, "Invalid rank `"+tk.Lexeme+"´")
 			}
 			rank = int(n)
+			if n >= 0 { rank = int(n)+1 }
 		}
 	}
 
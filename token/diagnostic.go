@@ -0,0 +1,94 @@
+package token
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+type Severity int
+
+const (
+    ERROR Severity = iota
+    WARNING
+)
+
+var SEVERITY_NAMES = [...]string{
+    ERROR: "error",
+    WARNING: "warning",
+}
+
+type Diagnostic struct {
+    File      string
+    Line      int
+    Column    int
+    Length    int
+    Message   string
+    Severity  Severity
+}
+
+func NewDiagnostic(file string, where Token, message string) Diagnostic {
+    return SpanDiagnostic(file, where.Span(), message)
+}
+
+func SpanDiagnostic(file string, span Span, message string) Diagnostic {
+    length := 0
+    if span.End.Line == span.Start.Line {
+        length = span.End.Column-span.Start.Column
+    }
+    return Diagnostic {
+        File: file,
+        Line: span.Start.Line,
+        Column: span.Start.Column,
+        Length: length,
+        Message: message,
+        Severity: ERROR,
+    }
+}
+
+func (d Diagnostic) Error() string {
+    if d.Column == 0 {
+        return fmt.Sprintf("%s:%d: %s: %s", d.File, d.Line, SEVERITY_NAMES[d.Severity], d.Message)
+    }
+    return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, SEVERITY_NAMES[d.Severity], d.Message)
+}
+
+func (d Diagnostic) Excerpt(source string) string {
+    lines := strings.Split(source, "\n")
+    if d.Line < 1 || d.Line > len(lines) || d.Column == 0 {
+        return ""
+    }
+
+    line := strings.TrimRight(lines[d.Line-1], "\r")
+    var caret strings.Builder
+    for i, r := range []rune(line) {
+        if i >= d.Column-1 { break }
+        if r == '\t' {
+            caret.WriteRune('\t')
+        } else {
+            caret.WriteRune(' ')
+        }
+    }
+    caret.WriteRune('^')
+    if d.Length > 1 {
+        caret.WriteString(strings.Repeat("~", d.Length-1))
+    }
+
+    return line+"\n"+caret.String()
+}
+
+func HasErrors(diagnostics []Diagnostic) bool {
+    for _, d := range diagnostics {
+        if d.Severity == ERROR { return true }
+    }
+    return false
+}
+
+func SortDiagnostics(diagnostics []Diagnostic) {
+    sort.SliceStable(diagnostics, func(i, j int) bool {
+        if diagnostics[i].Line != diagnostics[j].Line {
+            return diagnostics[i].Line < diagnostics[j].Line
+        }
+        return diagnostics[i].Column < diagnostics[j].Column
+    })
+}
@@ -35,30 +35,33 @@ const (
 )
 
 type Scanner struct {
-    tokens   []Token
-    lexeme   strings.Builder
-    source   string
-    line     int
-    start    int
-    curr     int
-    mode     []int
-    reporter ErrorReporter
-    hasError bool
-}
-
-type ErrorReporter interface {
-    Report(line int, where string, message string)
+    tokens      []Token
+    lexeme      strings.Builder
+    source      string
+    file        string
+    line        int
+    lineStart   int
+    start       int
+    startLine   int
+    startCol    int
+    curr        int
+    mode        []int
+    quotes      []Pos
+    diagnostics []Diagnostic
 }
 
 var eof = rune(0)
 
-func NewScanner(source string, reporter ErrorReporter) Scanner {
+func NewScanner(source string, file string) Scanner {
     return Scanner {
         tokens: nil,
         source: source,
-        reporter: reporter,
+        file: file,
         line: 1,
+        lineStart: 0,
         start: 0,
+        startLine: 1,
+        startCol: 1,
         curr: 0,
         mode: []int{MAIN_MODE},
     }
@@ -71,6 +74,10 @@ func (s *Scanner) Read() rune {
     }
     s.lexeme.WriteRune(r)
     s.curr += size
+    if r == '\n' {
+        s.line += 1
+        s.lineStart = s.curr
+    }
     return r
 }
 
@@ -101,8 +108,29 @@ func (s *Scanner) Match(expected rune) bool {
 }
 
 func (s *Scanner) Report(message string) {
-    s.hasError = true
-    s.reporter.Report(s.line, s.lexeme.String(), message)
+    s.diagnostics = append(s.diagnostics, SpanDiagnostic(s.file, Span {
+        Pos { s.startLine, s.startCol, s.start },
+        Pos { s.line, s.column(), s.curr },
+    }, message))
+}
+
+// Reports an error at the single character at pos.
+func (s *Scanner) ReportAt(pos Pos, message string) {
+    s.diagnostics = append(s.diagnostics, SpanDiagnostic(s.file, Span {
+        pos,
+        Pos { pos.Line, pos.Column+1, pos.Offset+1 },
+    }, message))
+}
+
+func (s *Scanner) column() int {
+    return utf8.RuneCountInString(s.source[s.lineStart:s.curr])+1
+}
+
+func (s *Scanner) resetLexeme() {
+    s.lexeme.Reset()
+    s.start = s.curr
+    s.startLine = s.line
+    s.startCol = s.column()
 }
 
 func (s *Scanner) pushLiteral(kind TokenKind, stringValue string) {
@@ -110,7 +138,10 @@ func (s *Scanner) pushLiteral(kind TokenKind, stringValue string) {
         Kind: kind,
         Lexeme: s.lexeme.String(),
         Value: stringValue,
-        Line: s.line,
+        Line: s.startLine,
+        Column: s.startCol,
+        Offset: s.start,
+        End: Pos { s.line, s.column(), s.curr },
     })
 }
 
@@ -126,7 +157,7 @@ func (s *Scanner) lastEndsExpr() bool {
     return TokenEndsExpression(tk)
 }
 
-func (scanner *Scanner) Scan() ([]Token, bool) {
+func (scanner *Scanner) Scan() ([]Token, []Diagnostic) {
     for {
         if scanner.Peek() == eof {
             break
@@ -135,17 +166,20 @@ func (scanner *Scanner) Scan() ([]Token, bool) {
         mode := scanner.mode[len(scanner.mode)-1]
         switch mode {
         case MAIN_MODE:
-            scanner.lexeme.Reset()
+            scanner.resetLexeme()
             scanner.scanToken(mode)
         case STR_MODE:
             scanner.scanString()
         }
     }
 
-    scanner.lexeme.Reset()
+    scanner.resetLexeme()
+    if len(scanner.quotes) > 0 {
+        scanner.ReportAt(scanner.quotes[len(scanner.quotes)-1], "Unterminated string.")
+    }
     scanner.push(EOF)
 
-    return scanner.tokens, scanner.hasError
+    return scanner.tokens, scanner.diagnostics
 }
 
 func (scanner *Scanner) scanToken(mode int) {
@@ -153,7 +187,6 @@ func (scanner *Scanner) scanToken(mode int) {
     switch tk {
     case ' ', '\r', '\t':
     case '\n':
-        scanner.line += 1
         if scanner.lastEndsExpr() {
             scanner.push(TERMINATOR)
         }
@@ -168,9 +201,11 @@ func (scanner *Scanner) scanToken(mode int) {
         scanner.mode = append(scanner.mode, MAIN_MODE)
         scanner.push(LEFT_BLOCK)
     case '}':
-        scanner.mode = scanner.mode[:len(scanner.mode)-1]
+        if len(scanner.mode) > 1 {
+            scanner.mode = scanner.mode[:len(scanner.mode)-1]
+        }
         scanner.push(RIGHT_BLOCK)
-        scanner.lexeme.Reset()
+        scanner.resetLexeme()
     case '&':
         peek_tk := scanner.Peek()
         if isNameFirst(peek_tk) {
@@ -196,6 +231,11 @@ func (scanner *Scanner) scanToken(mode int) {
         }
     case '.':
         if scanner.Match('.') {
+            if scanner.Peek() == '.' && isNameFirst(scanner.Peek2()) {
+                scanner.Read()
+                scanner.push(ELLIPSIS)
+                return
+            }
             for {
                 if tk := scanner.Peek(); tk == '\n' || tk == eof {
                     break
@@ -207,12 +247,20 @@ func (scanner *Scanner) scanToken(mode int) {
         }
     case '"':
         scanner.mode = append(scanner.mode, STR_MODE)
+        scanner.quotes = append(scanner.quotes, Pos { scanner.startLine, scanner.startCol, scanner.start })
         scanner.push(STRING_BEGIN)
-        scanner.lexeme.Reset()
+        scanner.resetLexeme()
     case '\'':
         str := scanner.scanRawString()
         scanner.pushLiteral(RAW_STRING, str)
     case '#':
+        if scanner.Peek() == '[' {
+            scanner.push(HASH)
+            scanner.resetLexeme()
+            scanner.Read()
+            scanner.push(LEFT_LIST)
+            return
+        }
         peek_tk := scanner.Read()
         if peek_tk == '\'' {
             str := scanner.scanRawString()
@@ -276,8 +324,17 @@ func (scanner *Scanner) scanToken(mode int) {
             case "nonlocal": scanner.push(NONLOCAL);
             case "loop": scanner.push(LOOP);
             case "if": scanner.push(IF);
+            case "else":
+                if len(scanner.tokens) > 0 && scanner.tokens[len(scanner.tokens)-1].Kind == TERMINATOR {
+                    scanner.tokens = scanner.tokens[:len(scanner.tokens)-1]
+                }
+                scanner.push(ELSE);
+            case "for": scanner.push(FOR);
+            case "in": scanner.push(IN);
+            case "while": scanner.push(WHILE);
             case "import": scanner.push(IMPORT);
             case "type": scanner.push(TYPE);
+            case "yield": scanner.push(YIELD);
             default:
                 scanner.pushLiteral(NAME, name)
             }
@@ -293,44 +350,42 @@ func (scanner *Scanner) scanString() {
     switch tk {
     case '"':
         scanner.pushLiteral(STRING_LITERAL, scanner.lexeme.String())
-        scanner.lexeme.Reset()
+        scanner.resetLexeme()
 
         scanner.Read()
         scanner.mode = scanner.mode[:len(scanner.mode)-1]
+        scanner.quotes = scanner.quotes[:len(scanner.quotes)-1]
         scanner.push(STRING_END)
     case '\\':
         scanner.pushLiteral(STRING_LITERAL, scanner.lexeme.String())
-        scanner.lexeme.Reset()
+        scanner.resetLexeme()
 
         scanner.Read()
         tk = scanner.Read()
         switch tk {
             case 'n':
                 scanner.pushLiteral(STRING_LITERAL, "\n")
-                scanner.lexeme.Reset()
+                scanner.resetLexeme()
             case 't':
                 scanner.pushLiteral(STRING_LITERAL, "\t")
-                scanner.lexeme.Reset()
+                scanner.resetLexeme()
             case 'r':
                 scanner.pushLiteral(STRING_LITERAL, "\r")
-                scanner.lexeme.Reset()
+                scanner.resetLexeme()
             case '\\':
                 scanner.pushLiteral(STRING_LITERAL, "\\")
-                scanner.lexeme.Reset()
+                scanner.resetLexeme()
             case '"':
                 scanner.pushLiteral(STRING_LITERAL, "\"")
-                scanner.lexeme.Reset()
+                scanner.resetLexeme()
             case '#':
                 scanner.pushLiteral(STRING_LITERAL, "#")
-                scanner.lexeme.Reset()
-            case eof:
-                scanner.Report("Unterminated string.")
-                return
+                scanner.resetLexeme()
         }
     case '#':
         if scanner.Peek2() == '{' {
             scanner.pushLiteral(STRING_LITERAL, scanner.lexeme.String())
-            scanner.lexeme.Reset()
+            scanner.resetLexeme()
 
             scanner.Read()
             scanner.Read()
@@ -368,7 +423,7 @@ func (scanner *Scanner) scanRawString() string {
     }
 
     if tk == eof {
-        scanner.Report("Unterminated string.")
+        scanner.ReportAt(Pos { scanner.startLine, scanner.startCol, scanner.start }, "Unterminated string.")
         return ""
     }
 
@@ -25,6 +25,7 @@ const (
     ASSIGN
     CASCADE
     HASH
+    ELLIPSIS
     TERMINATOR
     SEPARATOR
     AT
@@ -42,21 +43,48 @@ const (
     NONLOCAL
     LOOP
     IF
+    ELSE
+    FOR
+    IN
+    WHILE
     IMPORT
     TYPE
+    YIELD
 )
 
+type Pos struct {
+    Line    int
+    Column  int
+    Offset  int
+}
+
+type Span struct {
+    Start  Pos
+    End    Pos
+}
+
 type Token struct {
     Kind       TokenKind
     Lexeme     string
     Value      string
     Line       int
+    Column     int
+    Offset     int
+    End        Pos
+}
+
+func (tk Token) Pos() Pos {
+    return Pos { tk.Line, tk.Column, tk.Offset }
+}
+
+func (tk Token) Span() Span {
+    return Span { tk.Pos(), tk.End }
 }
 
 func TokenEndsExpression(tk Token) bool {
     switch tk.Kind {
     case ASSIGN, LEFT_PAREN, LEFT_BLOCK, LEFT_LIST,
-        PIPE, OPERATOR, TERMINATOR, CASCADE, SEPARATOR:
+        PIPE, OPERATOR, TERMINATOR, CASCADE, SEPARATOR, ELSE, IN:
         return false
     }
     return true